@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"ticketapp/internal/middlewares"
 	"ticketapp/internal/models"
 	"ticketapp/internal/repositories"
 	"ticketapp/internal/services"
@@ -11,23 +16,42 @@ import (
 
 	"github.com/google/uuid"
 )
+// inviteTTL is how long a new user's set-password link stays valid.
+const inviteTTL = 72 * time.Hour
+
 type AdminHandler struct {
-	userRepo repositories.UserRepository
-	emailSvc *services.EmailService
+	userRepo   repositories.UserRepository
+	orgRepo    repositories.OrganizationRepository
+	tokenRepo  repositories.RefreshTokenRepository
+	denylist   services.TokenDenylist
+	auditRepo  repositories.AuditRepository
+	loginGuard *services.LoginGuard
+	emailSvc   *services.EmailService
 }
 func NewAdminHandler(
 	userRepo repositories.UserRepository,
+	orgRepo repositories.OrganizationRepository,
+	tokenRepo repositories.RefreshTokenRepository,
+	denylist services.TokenDenylist,
+	auditRepo repositories.AuditRepository,
+	loginGuard *services.LoginGuard,
 	emailSvc *services.EmailService,
 ) *AdminHandler {
 	return &AdminHandler{
-		userRepo: userRepo,
-		emailSvc: emailSvc,
+		userRepo:   userRepo,
+		orgRepo:    orgRepo,
+		tokenRepo:  tokenRepo,
+		denylist:   denylist,
+		auditRepo:  auditRepo,
+		loginGuard: loginGuard,
+		emailSvc:   emailSvc,
 	}
 }
 func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email string `json:"email"`
-		Role  string `json:"role"` // support | customer
+		Email          string `json:"email"`
+		Role           string `json:"role"`            // support | customer
+		OrganizationID string `json:"organization_id"` // customers only, optional
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -40,8 +64,32 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tempPassword := uuid.NewString()[:10]
-	passwordHash, _ := utils.HashPassword(tempPassword)
+	var orgID *uuid.UUID
+	if req.OrganizationID != "" {
+		if req.Role != models.RoleCustomer {
+			http.Error(w, "only customers belong to organizations", http.StatusBadRequest)
+			return
+		}
+
+		id, err := uuid.Parse(req.OrganizationID)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.orgRepo.GetByID(id); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				http.Error(w, "organization not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load organization", http.StatusInternalServerError)
+			return
+		}
+		orgID = &id
+	}
+
+	// random password nobody knows; the user sets their own from the invite
+	passwordHash, _ := utils.HashPassword(uuid.NewString())
 
 	user := models.User{
 		ID:                    uuid.New(),
@@ -52,19 +100,30 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		IsActive:              true,
 		PasswordResetRequired: true,
 		Is2FAEnabled:          false,
+		OrganizationID:        orgID,
 	}
 
 	if err := h.userRepo.Create(user); err != nil {
-		http.Error(w, "user already exists", http.StatusConflict)
+		if errors.Is(err, repositories.ErrConflict) {
+			http.Error(w, "user already exists", http.StatusConflict)
+			return
+		}
+		log.Println("create user:", err)
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(h.auditRepo, r, models.AuditUserCreated, &user.ID, map[string]any{"role": user.Role})
 
-	// Send onboarding email
-	h.emailSvc.SendUserInvite(
-		user.Email,
-		user.Username,
-		tempPassword,
-	)
+	// Send onboarding email with a set-password link
+	token := uuid.NewString()
+	if err := h.userRepo.StoreResetToken(user.ID, services.HashToken(token), time.Now().Add(inviteTTL)); err != nil {
+		http.Error(w, "failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.emailSvc.SendUserInvite(user.Email, user.Username, token, inviteTTL); err != nil {
+		log.Println("queue invite email:", err)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 }
@@ -81,6 +140,198 @@ func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
+	recordAudit(h.auditRepo, r, models.AuditUserDisabled, &uid, nil)
+
+	// the account is off; its live sessions and access tokens go with it
+	if err := signOutEverywhere(h.tokenRepo, h.denylist, uid); err != nil {
+		log.Println("sign out disabled user:", err)
+		http.Error(w, "user disabled but sessions not revoked", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// SetRole changes a user's role. Access tokens carrying the old role are
+// revoked, so it applies from the user's next refresh on; admins can't
+// change their own role.
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Role {
+	case models.RoleAdmin, models.RoleSupport, models.RoleCustomer:
+	default:
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if caller, _ := middlewares.UserIDFromContext(r.Context()); caller == uid {
+		http.Error(w, "cannot change your own role", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(uid)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.userRepo.SetRole(uid, req.Role); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to change role", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditRoleChanged, &uid, map[string]any{
+		"from": user.Role,
+		"to":   req.Role,
+	})
+
+	if err := revokeUserTokens(h.denylist, uid); err != nil {
+		log.Println("revoke tokens after role change:", err)
+		http.Error(w, "role changed but old tokens not revoked", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListLockouts returns the accounts and client IPs currently locked out of
+// password login.
+func (h *AdminHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.loginGuard.Lockouts()
+	if err != nil {
+		http.Error(w, "failed to list lockouts", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lockouts)
+}
+
+// ClearLockout lifts a lock and resets its failure count. scope is
+// "account" (key is the email) or "ip".
+func (h *AdminHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	scope := r.PathValue("scope")
+	if scope != models.LoginScopeAccount && scope != models.LoginScopeIP {
+		http.Error(w, "invalid scope", http.StatusBadRequest)
+		return
+	}
+
+	key := r.PathValue("key")
+	if err := h.loginGuard.Unlock(scope, key); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "lockout not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to clear lockout", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditLockoutCleared, nil, map[string]any{
+		"scope": scope,
+		"key":   key,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func auditFilterFromQuery(w http.ResponseWriter, r *http.Request) (repositories.AuditFilter, bool) {
+	q := r.URL.Query()
+
+	filter := repositories.AuditFilter{
+		Action: models.AuditAction(q.Get("action")),
+		IP:     q.Get("ip"),
+	}
+
+	if v := q.Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.UserID = &id
+	}
+	if v := q.Get("actor_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid actor id", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.ActorID = &id
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid from date", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid to date", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.To = &t
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.BeforeID = id
+	}
+
+	return filter, true
+}
+
+// ListAuditEvents pages through the audit trail, newest first. Filters:
+// user_id, actor_id, action, ip, from and to (RFC 3339). Pass the returned
+// next_cursor back as "cursor" to fetch the next page.
+func (h *AdminHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilterFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	// fetch one extra row to learn whether another page exists
+	filter.Limit = limit + 1
+
+	events, err := h.auditRepo.List(filter)
+	if err != nil {
+		http.Error(w, "failed to list audit events", http.StatusInternalServerError)
+		return
+	}
+
+	var next string
+	if len(events) > limit {
+		events = events[:limit]
+		next = strconv.FormatInt(events[limit-1].ID, 10)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"events":      events,
+		"next_cursor": next,
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type AgentHandler struct {
+	userRepo repositories.UserRepository
+}
+
+func NewAgentHandler(userRepo repositories.UserRepository) *AgentHandler {
+	return &AgentHandler{userRepo: userRepo}
+}
+
+// SetAvailability toggles whether a support agent receives new tickets.
+// Agents manage their own flag ("me"); admins may set it for anyone.
+func (h *AgentHandler) SetAvailability(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Available bool `json:"available"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	callerID, _ := middlewares.UserIDFromContext(r.Context())
+	role := middlewares.RoleFromContext(r.Context())
+
+	agentID := callerID
+	if v := r.PathValue("id"); v != "me" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+		agentID = id
+	}
+
+	if agentID != callerID && role != models.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := h.userRepo.SetAvailability(agentID, req.Available); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "agent not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to update availability", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+type AttachmentHandler struct {
+	ticketRepo     repositories.TicketRepository
+	commentRepo    repositories.CommentRepository
+	attachmentRepo repositories.AttachmentRepository
+	store          storage.BlobStore
+	maxSize        int64
+}
+
+func NewAttachmentHandler(
+	ticketRepo repositories.TicketRepository,
+	commentRepo repositories.CommentRepository,
+	attachmentRepo repositories.AttachmentRepository,
+	store storage.BlobStore,
+	maxSize int64,
+) *AttachmentHandler {
+	return &AttachmentHandler{
+		ticketRepo:     ticketRepo,
+		commentRepo:    commentRepo,
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		maxSize:        maxSize,
+	}
+}
+
+// Upload accepts a multipart form with a "file" part and an optional
+// "comment_id" field tying the file to a comment on the same ticket.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	staff := isStaff(middlewares.RoleFromContext(r.Context()))
+
+	// leave headroom for the multipart envelope and form fields
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > h.maxSize {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		http.Error(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
+	contentType, allowed := storage.SniffContentType(sniff[:n])
+	if !allowed {
+		http.Error(w, "file type not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New(),
+		TicketID:    ticket.ID,
+		Filename:    storage.SanitizeFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+	attachment.UploaderID, _ = middlewares.UserIDFromContext(r.Context())
+	attachment.StorageKey = "tickets/" + ticket.ID.String() + "/" + attachment.ID.String()
+
+	if v := r.FormValue("comment_id"); v != "" {
+		commentID, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid comment id", http.StatusBadRequest)
+			return
+		}
+
+		comment, err := h.commentRepo.GetByID(commentID)
+		if err != nil || comment.TicketID != ticket.ID || (comment.Internal && !staff) {
+			http.Error(w, "comment not found", http.StatusBadRequest)
+			return
+		}
+		attachment.CommentID = &commentID
+	}
+
+	if err := h.store.Put(r.Context(), attachment.StorageKey, file, attachment.Size, contentType); err != nil {
+		log.Println("attachment upload failed:", err)
+		http.Error(w, "failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.attachmentRepo.Create(attachment); err != nil {
+		_ = h.store.Delete(r.Context(), attachment.StorageKey)
+		http.Error(w, "failed to save attachment", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+func (h *AttachmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	staff := isStaff(middlewares.RoleFromContext(r.Context()))
+
+	attachments, err := h.attachmentRepo.ListByTicket(ticket.ID, staff)
+	if err != nil {
+		http.Error(w, "failed to list attachments", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("attachmentID"))
+	if err != nil {
+		http.Error(w, "invalid attachment id", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.attachmentRepo.GetByID(id)
+	if err != nil || attachment.TicketID != ticket.ID {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	}
+
+	// files on internal notes are as private as the note itself
+	if attachment.CommentID != nil && !isStaff(middlewares.RoleFromContext(r.Context())) {
+		comment, err := h.commentRepo.GetByID(*attachment.CommentID)
+		if err != nil || comment.Internal {
+			http.Error(w, "attachment not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	body, err := h.store.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			http.Error(w, "attachment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to read attachment", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+	)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, body)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// recordAudit appends a security event about userID (nil if unknown) for
+// request r. The caller, when authenticated and not userID, is recorded as
+// the actor. Failures are logged: an audit outage must not block logins.
+func recordAudit(
+	repo repositories.AuditRepository,
+	r *http.Request,
+	action models.AuditAction,
+	userID *uuid.UUID,
+	details map[string]any,
+) {
+	e := &models.AuditEvent{
+		Action:    action,
+		UserID:    userID,
+		IP:        middlewares.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   details,
+	}
+
+	if actor, ok := middlewares.UserIDFromContext(r.Context()); ok && (userID == nil || actor != *userID) {
+		e.ActorID = &actor
+	}
+
+	if err := repo.Log(e); err != nil {
+		log.Printf("audit %s: %v", action, err)
+	}
+}
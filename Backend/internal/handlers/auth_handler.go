@@ -2,7 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
 	"ticketapp/internal/repositories"
 	"ticketapp/internal/services"
 	"ticketapp/internal/utils"
@@ -11,25 +18,66 @@ import (
 	"github.com/google/uuid"
 )
 
+// passwordResetTTL is how long a password reset link stays valid.
+const passwordResetTTL = 15 * time.Minute
+
+// Password length bounds in bytes; bcrypt ignores or rejects anything past 72.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+const (
+	// mfaChallengeTTL is how long a user has to enter their OTP after the
+	// password step.
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaMaxAttempts is how many codes may be tried against one challenge
+	// before the user has to log in again.
+	mfaMaxAttempts = 5
+)
+
 type AuthHandler struct {
-	userRepo  repositories.UserRepository
-	tokenRepo repositories.RefreshTokenRepository
-	jwt       *services.JWTService
-	otp       *services.OTPService
+	userRepo      repositories.UserRepository
+	tokenRepo     repositories.RefreshTokenRepository
+	challengeRepo repositories.MFAChallengeRepository
+	auditRepo     repositories.AuditRepository
+	jwt           *services.JWTService
+	denylist      services.TokenDenylist
+	loginGuard    *services.LoginGuard
+	otp           *services.OTPService
+	// webauthn is nil when no relying party is configured.
+	webauthn *services.WebAuthnService
+	// oidc is nil when SSO is not configured.
+	oidc     *services.OIDCService
+	emailSvc *services.EmailService
 }
 
 
 func NewAuthHandler(
 	userRepo repositories.UserRepository,
 	tokenRepo repositories.RefreshTokenRepository,
+	challengeRepo repositories.MFAChallengeRepository,
+	auditRepo repositories.AuditRepository,
 	jwt *services.JWTService,
+	denylist services.TokenDenylist,
+	loginGuard *services.LoginGuard,
 	otp *services.OTPService,
+	webauthn *services.WebAuthnService,
+	oidc *services.OIDCService,
+	emailSvc *services.EmailService,
 ) *AuthHandler {
 	return &AuthHandler{
-		userRepo:  userRepo,
-		tokenRepo: tokenRepo,
-		jwt:       jwt,
-		otp:       otp,
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		challengeRepo: challengeRepo,
+		auditRepo:     auditRepo,
+		jwt:           jwt,
+		denylist:      denylist,
+		loginGuard:    loginGuard,
+		otp:           otp,
+		webauthn:      webauthn,
+		oidc:          oidc,
+		emailSvc:      emailSvc,
 	}
 }
 
@@ -45,13 +93,145 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := middlewares.ClientIP(r)
+
+	wait, err := h.loginGuard.Check(req.Email, ip)
+	if err != nil {
+		log.Println("check login failures:", err)
+	}
+	if wait > 0 {
+		recordAudit(h.auditRepo, r, models.AuditLoginFailed, nil, map[string]any{
+			"email":  services.LoginKey(req.Email),
+			"reason": "locked_out",
+		})
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "too many failed attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
 	user, err := h.userRepo.GetByEmail(req.Email)
-	if err != nil || utils.ComparePassword(user.PasswordHash, req.Password) != nil {
+	if err != nil {
+		// same bcrypt cost as a real account, so timing doesn't tell them apart
+		_ = utils.ComparePassword(dummyPasswordHash, req.Password)
+	}
+	if user == nil || utils.ComparePassword(user.PasswordHash, req.Password) != nil {
+		h.recordLoginFailure(r, req.Email, user, "invalid_credentials")
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	h.issueTokens(w, user.ID.String(), user.Role)
+	// a disabled account answers like a wrong password and must not clear
+	// the failure count, or it would become an unthrottled password oracle
+	if !user.IsActive {
+		h.recordLoginFailure(r, req.Email, user, "account_disabled")
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.loginGuard.Succeed(req.Email); err != nil {
+		log.Println("clear login failures:", err)
+	}
+
+	methods, err := h.mfaMethods(user)
+	if err != nil {
+		// fail closed: skipping the second factor on a lookup error would
+		// let a password alone through
+		log.Println("login: mfa methods:", err)
+		http.Error(w, "login failed", http.StatusInternalServerError)
+		return
+	}
+
+	if len(methods) > 0 {
+		h.issueMFAChallenge(w, user, services.AMRPassword, methods)
+		return
+	}
+
+	h.issueTokens(w, r, user, services.AMRPassword)
+}
+
+// mfaMethods lists the second factors the user has set up.
+func (h *AuthHandler) mfaMethods(user *models.User) ([]string, error) {
+	var methods []string
+	if user.Is2FAEnabled {
+		methods = append(methods, "totp")
+	}
+	if h.webauthn != nil {
+		ok, err := h.webauthn.HasCredentials(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			methods = append(methods, "webauthn")
+		}
+	}
+	return methods, nil
+}
+
+// dummyPasswordHash is compared against when the email is unknown.
+var dummyPasswordHash, _ = utils.HashPassword(uuid.NewString())
+
+// recordLoginFailure counts and audits a refused login and, when that
+// locks the account, emails its owner. user is nil for unknown emails.
+func (h *AuthHandler) recordLoginFailure(r *http.Request, email string, user *models.User, reason string) {
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+	key := services.LoginKey(email)
+
+	recordAudit(h.auditRepo, r, models.AuditLoginFailed, userID, map[string]any{
+		"email":  key,
+		"reason": reason,
+	})
+
+	lockedUntil, err := h.loginGuard.Fail(email, middlewares.ClientIP(r))
+	if err != nil {
+		log.Println("record login failure:", err)
+		return
+	}
+	if lockedUntil == nil {
+		return
+	}
+
+	recordAudit(h.auditRepo, r, models.AuditAccountLocked, userID, map[string]any{
+		"email":        key,
+		"locked_until": lockedUntil,
+	})
+	if user == nil {
+		return
+	}
+
+	if err := h.emailSvc.SendAccountLocked(
+		user.Email,
+		h.loginGuard.MaxAccountFailures(),
+		time.Until(*lockedUntil).Round(time.Minute),
+	); err != nil {
+		log.Println("queue account locked email:", err)
+	}
+}
+
+// issueMFAChallenge answers a passed first factor (a password or an SSO
+// login, see services.AMR*) on a 2FA account with a single-use token that
+// VerifyOTP exchanges, together with a code, for the real token pair.
+func (h *AuthHandler) issueMFAChallenge(w http.ResponseWriter, user *models.User, firstFactor string, methods []string) {
+	token := uuid.NewString()
+
+	if err := h.challengeRepo.Create(
+		user.ID,
+		services.HashToken(token),
+		firstFactor,
+		time.Now().Add(mfaChallengeTTL),
+	); err != nil {
+		http.Error(w, "could not start 2fa challenge", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mfa_required":    true,
+		"mfa_methods":     methods,
+		"challenge_token": token,
+		"expires_in":      int(mfaChallengeTTL.Seconds()),
+	})
 }
 
 
@@ -67,39 +247,51 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	token, err := h.tokenRepo.GetValid(hash)
 	if err != nil {
-		// 🚨 TOKEN REUSE / INVALID TOKEN
-		// revoke ALL sessions for this user
-		// (hash is untrusted at this point)
-		http.Error(w, "token reuse detected", http.StatusUnauthorized)
+		h.handleRefreshReuse(r, hash)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// rotate refresh token
-	_ = h.tokenRepo.Revoke(token.ID)
-
-	newRefresh := uuid.NewString()
-	newHash := services.HashToken(newRefresh)
-
-	_ = h.tokenRepo.Store(
-		token.UserID,
-		newHash,
-		time.Now().Add(7*24*time.Hour),
-	)
-
 	user, err := h.userRepo.GetByID(token.UserID)
-	if err != nil {
-		http.Error(w, "user not found", http.StatusUnauthorized)
+	if err != nil || !user.IsActive {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	h.issueTokens(w, user.ID.String(), user.Role)
+	// rotate refresh token
+	h.writeTokens(w, r, user, token, nil)
 }
 
+// handleRefreshReuse deals with a refresh token that is not currently valid.
+// Rotation means a legitimate client never presents a revoked token again,
+// so one that does was most likely copied: the whole session (token family)
+// is revoked, cutting off both the thief and the victim's copy.
+func (h *AuthHandler) handleRefreshReuse(r *http.Request, hash string) {
+	token, err := h.tokenRepo.GetByHash(hash)
+	if err != nil || !token.Revoked {
+		// unknown or merely expired
+		return
+	}
+
+	err = h.tokenRepo.RevokeSession(token.UserID, token.SessionID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		log.Println("revoke reused refresh token family:", err)
+	}
+	// the thief may already hold an access token from the stolen family
+	if err := revokeSessionTokens(h.denylist, token.SessionID); err != nil {
+		log.Println("denylist reused refresh token family:", err)
+	}
+
+	recordAudit(h.auditRepo, r, models.AuditRefreshTokenReuse, &token.UserID, map[string]any{
+		"session_id": token.SessionID,
+	})
+}
 
 func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		UserID string `json:"user_id"`
-		Code   string `json:"code"`
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -107,29 +299,117 @@ func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uid, err := uuid.Parse(req.UserID)
+	if req.ChallengeToken == "" {
+		http.Error(w, "challenge token required", http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := h.challengeRepo.ClaimAttempt(services.HashToken(req.ChallengeToken), mfaMaxAttempts)
+	if errors.Is(err, repositories.ErrNotFound) {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		http.Error(w, "invalid user id", http.StatusBadRequest)
+		http.Error(w, "could not verify otp", http.StatusInternalServerError)
 		return
 	}
+	uid := challenge.UserID
+
+	if req.RecoveryCode != "" {
+		hash := services.HashToken(services.NormalizeRecoveryCode(req.RecoveryCode))
+		if err := h.userRepo.UseRecoveryCode(uid, hash); err != nil {
+			recordAudit(h.auditRepo, r, models.AuditOTPFailed, &uid, map[string]any{"method": "recovery_code"})
+			http.Error(w, "invalid otp", http.StatusUnauthorized)
+			return
+		}
+	} else {
+		secret, err := h.userRepo.GetOTPSecret(uid)
+		if err != nil {
+			http.Error(w, "invalid otp", http.StatusUnauthorized)
+			return
+		}
+
+		step, ok := h.otp.Verify(secret, req.Code)
+		if !ok {
+			recordAudit(h.auditRepo, r, models.AuditOTPFailed, &uid, map[string]any{"method": "totp"})
+			http.Error(w, "invalid otp", http.StatusUnauthorized)
+			return
+		}
+
+		// a code already used to sign in is rejected like a wrong one
+		if err := h.userRepo.AcceptOTPStep(uid, step); err != nil {
+			recordAudit(h.auditRepo, r, models.AuditOTPFailed, &uid, map[string]any{
+				"method": "totp",
+				"reason": "replayed",
+			})
+			http.Error(w, "invalid otp", http.StatusUnauthorized)
+			return
+		}
+	}
 
-	secret, err := h.userRepo.GetOTPSecret(uid)
-	if err != nil || !h.otp.Verify(secret, req.Code) {
-		http.Error(w, "invalid otp", http.StatusUnauthorized)
+	// single use: a concurrent request with the same challenge loses here
+	if _, err := h.challengeRepo.Consume(challenge.ID); err != nil {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
 		return
 	}
 
 	user, err := h.userRepo.GetByID(uid)
-	if err != nil {
-		http.Error(w, "user not found", http.StatusUnauthorized)
+	if err != nil || !user.IsActive {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// ✅ CORRECT CALL — 3 ARGUMENTS
-	h.issueTokens(w, user.ID.String(), user.Role)
+	h.issueTokens(w, r, user, challenge.FirstFactor, services.AMROTP)
 }
 
 
+// Logout ends the caller's session: the refresh cookie's session is revoked,
+// every access token issued for it and the presented one are denylisted and
+// the cookie is cleared. It is best-effort and succeeds even when both tokens
+// are already dead.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie("refresh_token"); err == nil {
+		if token, err := h.tokenRepo.GetValid(services.HashToken(c.Value)); err == nil {
+			if err := h.tokenRepo.RevokeSession(token.UserID, token.SessionID); err != nil &&
+				!errors.Is(err, repositories.ErrNotFound) {
+				log.Println("logout: revoke session:", err)
+			}
+			if err := revokeSessionTokens(h.denylist, token.SessionID); err != nil {
+				log.Println("logout: denylist session:", err)
+			}
+		}
+	}
+
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if claims, err := h.jwt.Validate(bearer); err == nil {
+			if err := h.denylist.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+				log.Println("logout: denylist access token:", err)
+			}
+		}
+	}
+
+	clearRefreshCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll signs the caller out on every device, including this one.
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := signOutEverywhere(h.tokenRepo, h.denylist, userID); err != nil {
+		http.Error(w, "failed to sign out", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditSignedOutEverywhere, &userID, nil)
+
+	clearRefreshCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct{ Email string }
 	json.NewDecoder(r.Body).Decode(&req)
@@ -142,9 +422,13 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	token := uuid.NewString()
 	hash := services.HashToken(token)
 
-	h.userRepo.StoreResetToken(user.ID, hash, time.Now().Add(15*time.Minute))
+	if err := h.userRepo.StoreResetToken(user.ID, hash, time.Now().Add(passwordResetTTL)); err == nil {
+		recordAudit(h.auditRepo, r, models.AuditPasswordResetRequested, &user.ID, nil)
+		if err := h.emailSvc.SendPasswordReset(user.Email, token, passwordResetTTL); err != nil {
+			log.Println("queue password reset email:", err)
+		}
+	}
 
-	// send email with token (link)
 	w.WriteHeader(200)
 }
 func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
@@ -152,18 +436,38 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		Token    string
 		Password string
 	}
-	json.NewDecoder(r.Body).Decode(&req)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// everything that can fail goes before the token is spent
+	if len(req.Password) < minPasswordLength || len(req.Password) > maxPasswordLength {
+		http.Error(w, "password must be 8 to 72 bytes", http.StatusBadRequest)
+		return
+	}
+	pw, err := utils.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "failed to reset password", http.StatusInternalServerError)
+		return
+	}
 
 	hash := services.HashToken(req.Token)
-	userID, err := h.userRepo.ValidateResetToken(hash)
+	userID, err := h.userRepo.ConsumeResetToken(hash)
 	if err != nil {
 		http.Error(w, "invalid or expired", 400)
 		return
 	}
 
-	pw, _ := utils.HashPassword(req.Password)
-	h.userRepo.UpdatePassword(userID, pw)
-	h.tokenRepo.RevokeAll(userID)
+	if err := h.userRepo.UpdatePassword(userID, pw); err != nil {
+		log.Println("reset password: update:", err)
+		http.Error(w, "failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditPasswordReset, &userID, nil)
+	if err := signOutEverywhere(h.tokenRepo, h.denylist, userID); err != nil {
+		log.Println("reset password: sign out:", err)
+	}
 
 	w.WriteHeader(200)
 }
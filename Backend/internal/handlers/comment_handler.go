@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+
+	"github.com/google/uuid"
+)
+
+type CommentHandler struct {
+	ticketRepo  repositories.TicketRepository
+	commentRepo repositories.CommentRepository
+	userRepo    repositories.UserRepository
+	emailSvc    *services.EmailService
+}
+
+func NewCommentHandler(
+	ticketRepo repositories.TicketRepository,
+	commentRepo repositories.CommentRepository,
+	userRepo repositories.UserRepository,
+	emailSvc *services.EmailService,
+) *CommentHandler {
+	return &CommentHandler{
+		ticketRepo:  ticketRepo,
+		commentRepo: commentRepo,
+		userRepo:    userRepo,
+		emailSvc:    emailSvc,
+	}
+}
+
+func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body     string `json:"body"`
+		ParentID string `json:"parent_id"`
+		Internal bool   `json:"internal"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return
+	}
+
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	staff := isStaff(middlewares.RoleFromContext(r.Context()))
+
+	if req.Internal && !staff {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	comment := &models.TicketComment{
+		ID:       uuid.New(),
+		TicketID: ticket.ID,
+		Body:     req.Body,
+		Internal: req.Internal,
+	}
+	comment.AuthorID, _ = middlewares.UserIDFromContext(r.Context())
+
+	if req.ParentID != "" {
+		parentID, err := uuid.Parse(req.ParentID)
+		if err != nil {
+			http.Error(w, "invalid parent id", http.StatusBadRequest)
+			return
+		}
+
+		// the parent must be on this ticket and visible to the caller
+		parent, err := h.commentRepo.GetByID(parentID)
+		if err != nil || parent.TicketID != ticket.ID || (parent.Internal && !staff) {
+			http.Error(w, "parent comment not found", http.StatusBadRequest)
+			return
+		}
+		// a public reply would reveal the internal note through parent_id
+		if parent.Internal && !comment.Internal {
+			http.Error(w, "replies to internal notes must be internal", http.StatusBadRequest)
+			return
+		}
+		comment.ParentID = &parentID
+	}
+
+	if err := h.commentRepo.Create(comment); err != nil {
+		http.Error(w, "failed to create comment", http.StatusInternalServerError)
+		return
+	}
+
+	// the first public staff reply satisfies the first-response SLA
+	if staff && !comment.Internal {
+		if err := ticketsFor(r, h.ticketRepo).MarkFirstResponse(ticket.ID); err != nil {
+			log.Println("mark first response:", err)
+		}
+	}
+
+	// public staff replies go to the requester, customer replies to the assignee
+	switch {
+	case comment.Internal:
+	case staff && ticket.RequesterID != comment.AuthorID:
+		notifyTicketUser(h.userRepo, h.emailSvc, ticket.RequesterID, ticket, "Support replied to your ticket.", comment.Body)
+	case !staff && ticket.AssigneeID != nil:
+		notifyTicketUser(h.userRepo, h.emailSvc, *ticket.AssigneeID, ticket, "The customer replied on a ticket assigned to you.", comment.Body)
+	}
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (h *CommentHandler) List(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	staff := isStaff(middlewares.RoleFromContext(r.Context()))
+
+	comments, err := h.commentRepo.ListByTicket(ticket.ID, staff)
+	if err != nil {
+		http.Error(w, "failed to list comments", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comments)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"ticketapp/internal/services"
+)
+
+type InboundMailHandler struct {
+	ingest *services.MailIngestService
+	token  string
+}
+
+func NewInboundMailHandler(ingest *services.MailIngestService, token string) *InboundMailHandler {
+	return &InboundMailHandler{
+		ingest: ingest,
+		token:  token,
+	}
+}
+
+// Receive accepts a raw RFC 5322 message as the request body from the mail
+// provider's webhook. The provider authenticates with a shared token.
+func (h *InboundMailHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		http.Error(w, "inbound mail disabled", http.StatusNotFound)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Inbound-Token")), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 25<<20)
+
+	result, err := h.ingest.Ingest(r.Context(), r.Body)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, result)
+	case errors.Is(err, services.ErrDuplicateMessage), errors.Is(err, services.ErrAutoReply):
+		// nothing to do, but don't make the provider retry
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, services.ErrUnknownSender),
+		errors.Is(err, services.ErrUnverifiedSender),
+		errors.Is(err, services.ErrUnknownTicket),
+		errors.Is(err, services.ErrEmptyMessage):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to ingest message", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"ticketapp/internal/services"
+)
+
+// JWKSHandler publishes the access-token verification keys so other
+// services can validate our tokens without a shared secret.
+type JWKSHandler struct {
+	jwt *services.JWTService
+}
+
+func NewJWKSHandler(jwt *services.JWTService) *JWKSHandler {
+	return &JWKSHandler{jwt: jwt}
+}
+
+func (h *JWKSHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(services.JWKSMaxAge.Seconds())))
+	writeJSON(w, http.StatusOK, map[string]any{"keys": h.jwt.JWKS()})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// notifyTicketUser queues a ticket notification for userID. Failures are
+// logged; they never fail the request that triggered them.
+func notifyTicketUser(
+	userRepo repositories.UserRepository,
+	emailSvc *services.EmailService,
+	userID uuid.UUID,
+	ticket *models.Ticket,
+	headline string,
+	body string,
+) {
+	user, err := userRepo.GetByID(userID)
+	if err != nil || !user.IsActive || user.Email == "" {
+		return
+	}
+
+	if err := emailSvc.SendTicketNotification(user, ticket, headline, body); err != nil {
+		log.Println("queue ticket notification:", err)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"ticketapp/internal/services"
+)
+
+// oidcStateCookie binds an SSO login to the browser that started it, so a
+// victim cannot be made to finish an attacker's login.
+const oidcStateCookie = "oidc_state"
+
+func (h *AuthHandler) ssoEnabled(w http.ResponseWriter) bool {
+	if h.oidc == nil {
+		http.Error(w, "sso not configured", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// SSOLogin redirects the browser to the identity provider.
+func (h *AuthHandler) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoEnabled(w) {
+		return
+	}
+
+	state, url, err := h.oidc.AuthCodeURL()
+	if err != nil {
+		log.Println("start sso login:", err)
+		http.Error(w, "could not start sso login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		HttpOnly: true,
+		Secure:   true,
+		// Lax: the IdP sends the browser back with a cross-site navigation
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/auth/oidc",
+		MaxAge:   600,
+	})
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// SSOCallback finishes the login with the code and state the IdP returned.
+// It answers like Login: an access token plus the refresh cookie, or an MFA
+// challenge when the account has 2FA and the IdP did not report MFA.
+func (h *AuthHandler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoEnabled(w) {
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "sso login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	state := q.Get("state")
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		http.Error(w, "invalid sso state", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/auth/oidc",
+		MaxAge:   -1,
+	})
+
+	identity, err := h.oidc.Exchange(r.Context(), state, q.Get("code"))
+	if errors.Is(err, services.ErrOIDCState) {
+		http.Error(w, "invalid sso state", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("sso exchange:", err)
+		http.Error(w, "sso login failed", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.oidc.ResolveUser(identity)
+	if err != nil {
+		if !errors.Is(err, services.ErrSSONotAllowed) {
+			log.Println("sso resolve user:", err)
+		}
+		http.Error(w, "sso login not allowed", http.StatusForbidden)
+		return
+	}
+
+	if identity.MFA {
+		h.issueTokens(w, r, user, services.AMRFederated, services.AMRMultiFactor)
+		return
+	}
+
+	// the IdP vouches for one factor only: a 2FA account still owes its own
+	methods, err := h.mfaMethods(user)
+	if err != nil {
+		log.Println("sso mfa methods:", err)
+		http.Error(w, "sso login failed", http.StatusInternalServerError)
+		return
+	}
+	if len(methods) > 0 {
+		h.issueMFAChallenge(w, user, services.AMRFederated, methods)
+		return
+	}
+
+	h.issueTokens(w, r, user, services.AMRFederated)
+}
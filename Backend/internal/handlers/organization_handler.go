@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationHandler struct {
+	orgRepo  repositories.OrganizationRepository
+	userRepo repositories.UserRepository
+}
+
+func NewOrganizationHandler(
+	orgRepo repositories.OrganizationRepository,
+	userRepo repositories.UserRepository,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (h *OrganizationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	org := &models.Organization{
+		ID:   uuid.New(),
+		Name: req.Name,
+	}
+
+	if err := h.orgRepo.Create(org); err != nil {
+		http.Error(w, "organization already exists", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, org)
+}
+
+func (h *OrganizationHandler) List(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.orgRepo.List()
+	if err != nil {
+		http.Error(w, "failed to list organizations", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orgs)
+}
+
+// SetMembership moves a customer into an organization, or out of any
+// organization when organization_id is empty. The change applies to the
+// user's next access token.
+func (h *OrganizationHandler) SetMembership(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganizationID string `json:"organization_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if user.Role != models.RoleCustomer {
+		http.Error(w, "only customers belong to organizations", http.StatusBadRequest)
+		return
+	}
+
+	var orgID *uuid.UUID
+	if req.OrganizationID != "" {
+		id, err := uuid.Parse(req.OrganizationID)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.orgRepo.GetByID(id); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				http.Error(w, "organization not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load organization", http.StatusInternalServerError)
+			return
+		}
+		orgID = &id
+	}
+
+	if err := h.userRepo.SetOrganization(userID, orgID); err != nil {
+		http.Error(w, "failed to update membership", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// SessionHandler lets users see and end their signed-in sessions. Routes take
+// a user id, "me" for the caller; admins may manage anyone's sessions.
+type SessionHandler struct {
+	tokenRepo repositories.RefreshTokenRepository
+	denylist  services.TokenDenylist
+}
+
+func NewSessionHandler(
+	tokenRepo repositories.RefreshTokenRepository,
+	denylist services.TokenDenylist,
+) *SessionHandler {
+	return &SessionHandler{tokenRepo: tokenRepo, denylist: denylist}
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.tokenRepo.ListSessions(userID)
+	if err != nil {
+		http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if current, ok := middlewares.SessionIDFromContext(r.Context()); ok {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// Revoke ends one session and rejects the access tokens issued for it.
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokenRepo.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeSessionTokens(h.denylist, sessionID); err != nil {
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOthers ends every session except the one making the request. When an
+// admin targets another user, there is no such session and all are ended.
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	callerID, _ := middlewares.UserIDFromContext(r.Context())
+
+	current, ok := middlewares.SessionIDFromContext(r.Context())
+	if !ok || userID != callerID {
+		// nothing to keep: every refresh and access token goes
+		if err := signOutEverywhere(h.tokenRepo, h.denylist, userID); err != nil {
+			http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	sessions, err := h.tokenRepo.ListSessions(userID)
+	if err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokenRepo.RevokeOtherSessions(userID, current); err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	for _, s := range sessions {
+		if s.ID == current {
+			continue
+		}
+		if err := revokeSessionTokens(h.denylist, s.ID); err != nil {
+			http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionOwner resolves the {id} path value and checks the caller may act on it.
+func sessionOwner(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	callerID, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	userID := callerID
+	if v := r.PathValue("id"); v != "me" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return uuid.Nil, false
+		}
+		userID = id
+	}
+
+	if userID != callerID && middlewares.RoleFromContext(r.Context()) != models.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type SLAPolicyHandler struct {
+	slaRepo repositories.SLAPolicyRepository
+}
+
+func NewSLAPolicyHandler(slaRepo repositories.SLAPolicyRepository) *SLAPolicyHandler {
+	return &SLAPolicyHandler{slaRepo: slaRepo}
+}
+
+func (h *SLAPolicyHandler) List(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.slaRepo.List()
+	if err != nil {
+		http.Error(w, "failed to list policies", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policies)
+}
+
+// Upsert creates or replaces the policy for an (organization, priority) pair.
+func (h *SLAPolicyHandler) Upsert(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganizationID       string `json:"organization_id"` // empty = default policy
+		Priority             string `json:"priority"`
+		FirstResponseMinutes int    `json:"first_response_minutes"`
+		ResolutionMinutes    int    `json:"resolution_minutes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !models.ValidTicketPriority(req.Priority) {
+		http.Error(w, "invalid priority", http.StatusBadRequest)
+		return
+	}
+
+	if req.FirstResponseMinutes <= 0 || req.ResolutionMinutes < req.FirstResponseMinutes {
+		http.Error(w, "invalid targets", http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.SLAPolicy{
+		ID:                   uuid.New(),
+		Priority:             req.Priority,
+		FirstResponseMinutes: req.FirstResponseMinutes,
+		ResolutionMinutes:    req.ResolutionMinutes,
+	}
+
+	if req.OrganizationID != "" {
+		orgID, err := uuid.Parse(req.OrganizationID)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+		policy.OrganizationID = &orgID
+	}
+
+	if err := h.slaRepo.Upsert(policy); err != nil {
+		http.Error(w, "failed to save policy", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policy)
+}
+
+func (h *SLAPolicyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid policy id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.slaRepo.Delete(id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "policy not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+	"ticketapp/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+type TicketHandler struct {
+	ticketRepo     repositories.TicketRepository
+	userRepo       repositories.UserRepository
+	attachmentRepo repositories.AttachmentRepository
+	store          storage.BlobStore
+	tickets        *services.TicketService
+	workflow       *services.TicketWorkflow
+	sla            *services.SLAService
+	emailSvc       *services.EmailService
+}
+
+func NewTicketHandler(
+	ticketRepo repositories.TicketRepository,
+	userRepo repositories.UserRepository,
+	attachmentRepo repositories.AttachmentRepository,
+	store storage.BlobStore,
+	tickets *services.TicketService,
+	workflow *services.TicketWorkflow,
+	sla *services.SLAService,
+	emailSvc *services.EmailService,
+) *TicketHandler {
+	return &TicketHandler{
+		ticketRepo:     ticketRepo,
+		userRepo:       userRepo,
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		tickets:        tickets,
+		workflow:       workflow,
+		sla:            sla,
+		emailSvc:       emailSvc,
+	}
+}
+
+func isStaff(role string) bool {
+	return role == models.RoleSupport || role == models.RoleAdmin
+}
+
+// ticketsFor returns the ticket repository scoped to the caller's tenant.
+// Staff see every ticket; customers see their organization's tickets, or
+// only their own when they do not belong to one.
+func ticketsFor(r *http.Request, ticketRepo repositories.TicketRepository) repositories.TicketRepository {
+	ctx := r.Context()
+
+	var orgID *uuid.UUID
+	if id, ok := middlewares.OrganizationIDFromContext(ctx); ok {
+		orgID = &id
+	}
+
+	// an unparseable subject scopes to uuid.Nil, which matches nothing
+	userID, _ := middlewares.UserIDFromContext(ctx)
+
+	scope := repositories.ScopeFor(middlewares.RoleFromContext(ctx), userID, orgID)
+	if scope == (repositories.TicketScope{}) {
+		return ticketRepo
+	}
+	return ticketRepo.Scoped(scope)
+}
+
+// loadTicket fetches the ticket named by the {id} path value through the
+// caller's tenant scope. Tickets outside it are reported as not found so
+// ticket ids are not leaked.
+func loadTicket(
+	w http.ResponseWriter,
+	r *http.Request,
+	ticketRepo repositories.TicketRepository,
+) (*models.Ticket, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid ticket id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	ticket, err := ticketsFor(r, ticketRepo).GetByID(id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "ticket not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "failed to load ticket", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return ticket, true
+}
+
+func (h *TicketHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subject     string `json:"subject"`
+		Description string `json:"description"`
+		Priority    string `json:"priority"`
+		RequesterID string `json:"requester_id"` // staff only, open on behalf of a customer
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req.Subject = strings.TrimSpace(req.Subject)
+	if req.Subject == "" {
+		http.Error(w, "subject is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Priority == "" {
+		req.Priority = models.TicketPriorityNormal
+	}
+	if !models.ValidTicketPriority(req.Priority) {
+		http.Error(w, "invalid priority", http.StatusBadRequest)
+		return
+	}
+
+	requesterID, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var orgID *uuid.UUID
+	if id, ok := middlewares.OrganizationIDFromContext(r.Context()); ok {
+		orgID = &id
+	}
+
+	if req.RequesterID != "" && isStaff(middlewares.RoleFromContext(r.Context())) {
+		id, err := uuid.Parse(req.RequesterID)
+		if err != nil {
+			http.Error(w, "invalid requester id", http.StatusBadRequest)
+			return
+		}
+
+		requester, err := h.userRepo.GetByID(id)
+		if err != nil || !requester.IsActive {
+			http.Error(w, "requester not found", http.StatusBadRequest)
+			return
+		}
+		requesterID = requester.ID
+		orgID = requester.OrganizationID
+	}
+
+	ticket := &models.Ticket{
+		ID:          uuid.New(),
+		Subject:     req.Subject,
+		Description: req.Description,
+		Status:      models.TicketStatusOpen,
+		Priority:    req.Priority,
+		RequesterID: requesterID,
+		CreatedAt:   time.Now(),
+
+		OrganizationID: orgID,
+	}
+
+	if err := h.tickets.Open(ticketsFor(r, h.ticketRepo), ticket); err != nil {
+		http.Error(w, "failed to create ticket", http.StatusInternalServerError)
+		return
+	}
+
+	ticket.SLA = h.sla.Status(ticket, time.Now())
+	writeJSON(w, http.StatusCreated, ticket)
+}
+
+// ticketFilterFromQuery reads listing filters from the query string.
+// Tenant restrictions are applied by the scoped repository, not here.
+func ticketFilterFromQuery(w http.ResponseWriter, r *http.Request) (repositories.TicketFilter, bool) {
+	q := r.URL.Query()
+
+	filter := repositories.TicketFilter{
+		Status:   q.Get("status"),
+		Priority: q.Get("priority"),
+		Query:    strings.TrimSpace(q.Get("q")),
+	}
+
+	if v := q.Get("requester_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid requester id", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.RequesterID = &id
+	}
+	if v := q.Get("assignee_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid assignee id", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.AssigneeID = &id
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid from date", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.CreatedFrom = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid to date", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.CreatedTo = &t
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := repositories.DecodeTicketCursor(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return filter, false
+		}
+		filter.After = cursor
+	}
+
+	return filter, true
+}
+
+func (h *TicketHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ticketFilterFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := ticketsFor(r, h.ticketRepo).List(filter)
+	if err != nil {
+		http.Error(w, "failed to list tickets", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	for i := range tickets {
+		tickets[i].SLA = h.sla.Status(&tickets[i], now)
+	}
+
+	writeJSON(w, http.StatusOK, tickets)
+}
+
+// Search pages through tickets matching the "q" keywords and filters.
+// Pass the returned next_cursor back as "cursor" to fetch the next page.
+func (h *TicketHandler) Search(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ticketFilterFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	// one row under the repository cap, leaving room for the lookahead row
+	limit := 25
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v < repositories.MaxTicketListLimit {
+		limit = v
+	}
+	// fetch one extra row to learn whether another page exists
+	filter.Limit = limit + 1
+
+	tickets, err := ticketsFor(r, h.ticketRepo).List(filter)
+	if err != nil {
+		http.Error(w, "failed to search tickets", http.StatusInternalServerError)
+		return
+	}
+
+	var next string
+	if len(tickets) > limit {
+		tickets = tickets[:limit]
+		last := tickets[limit-1]
+		next = repositories.TicketCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	now := time.Now()
+	for i := range tickets {
+		tickets[i].SLA = h.sla.Status(&tickets[i], now)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tickets":     tickets,
+		"next_cursor": next,
+	})
+}
+
+func (h *TicketHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	ticket.SLA = h.sla.Status(ticket, time.Now())
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+func (h *TicketHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subject     *string `json:"subject"`
+		Description *string `json:"description"`
+		Status      *string `json:"status"` // rejected, use Transition
+		Priority    *string `json:"priority"`
+		AssigneeID  *string `json:"assignee_id"` // "" unassigns
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	if req.Status != nil {
+		http.Error(w, "status changes go through /tickets/{id}/transitions", http.StatusBadRequest)
+		return
+	}
+
+	staff := isStaff(middlewares.RoleFromContext(r.Context()))
+
+	// customers may only edit the text of their own tickets; the tenant
+	// scope alone would let them edit a colleague's
+	if !staff {
+		callerID, _ := middlewares.UserIDFromContext(r.Context())
+		if ticket.RequesterID != callerID || req.Priority != nil || req.AssigneeID != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	if req.Subject != nil {
+		subject := strings.TrimSpace(*req.Subject)
+		if subject == "" {
+			http.Error(w, "subject is required", http.StatusBadRequest)
+			return
+		}
+		ticket.Subject = subject
+	}
+
+	if req.Description != nil {
+		ticket.Description = *req.Description
+	}
+
+	if req.Priority != nil && *req.Priority != ticket.Priority {
+		if !models.ValidTicketPriority(*req.Priority) {
+			http.Error(w, "invalid priority", http.StatusBadRequest)
+			return
+		}
+		ticket.Priority = *req.Priority
+
+		if err := h.tickets.StampSLA(ticket); err != nil {
+			http.Error(w, "failed to update ticket", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.AssigneeID != nil {
+		if *req.AssigneeID == "" {
+			ticket.AssigneeID = nil
+		} else {
+			id, err := uuid.Parse(*req.AssigneeID)
+			if err != nil {
+				http.Error(w, "invalid assignee id", http.StatusBadRequest)
+				return
+			}
+
+			assignee, err := h.userRepo.GetByID(id)
+			if err != nil || !assignee.IsActive || !isStaff(assignee.Role) {
+				http.Error(w, "assignee must be an active support agent", http.StatusBadRequest)
+				return
+			}
+			ticket.AssigneeID = &id
+		}
+	}
+
+	if err := ticketsFor(r, h.ticketRepo).Update(ticket); err != nil {
+		http.Error(w, "failed to update ticket", http.StatusInternalServerError)
+		return
+	}
+
+	ticket.SLA = h.sla.Status(ticket, time.Now())
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+func (h *TicketHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid ticket id", http.StatusBadRequest)
+		return
+	}
+
+	// collect the blob keys first: the rows go with the ticket
+	attachments, err := h.attachmentRepo.ListByTicket(id, true)
+	if err != nil {
+		http.Error(w, "failed to delete ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if err := ticketsFor(r, h.ticketRepo).Delete(id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "ticket not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete ticket", http.StatusInternalServerError)
+		return
+	}
+
+	for _, a := range attachments {
+		if err := h.store.Delete(r.Context(), a.StorageKey); err != nil {
+			log.Printf("delete blob %s of ticket %s: %v", a.StorageKey, id, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Transition moves a ticket through the status workflow and records history.
+func (h *TicketHandler) Transition(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !models.ValidTicketStatus(req.Status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	userID, _ := middlewares.UserIDFromContext(r.Context())
+	role := middlewares.RoleFromContext(r.Context())
+
+	if err := h.workflow.Check(ticket.Status, req.Status, role, ticket.RequesterID == userID); err != nil {
+		if errors.Is(err, services.ErrTransitionForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	change := &models.TicketStatusChange{
+		ID:         uuid.New(),
+		TicketID:   ticket.ID,
+		FromStatus: ticket.Status,
+		ToStatus:   req.Status,
+		ChangedBy:  userID,
+		Note:       req.Note,
+	}
+
+	updated, err := ticketsFor(r, h.ticketRepo).Transition(change)
+	if err != nil {
+		if errors.Is(err, repositories.ErrConflict) {
+			http.Error(w, "ticket status changed, reload and retry", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to update ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if updated.RequesterID != userID {
+		notifyTicketUser(
+			h.userRepo, h.emailSvc, updated.RequesterID, updated,
+			"Your ticket is now "+strings.ReplaceAll(updated.Status, "_", " ")+".",
+			req.Note,
+		)
+	}
+
+	updated.SLA = h.sla.Status(updated, time.Now())
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *TicketHandler) History(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := loadTicket(w, r, h.ticketRepo)
+	if !ok {
+		return
+	}
+
+	history, err := ticketsFor(r, h.ticketRepo).ListStatusHistory(ticket.ID)
+	if err != nil {
+		http.Error(w, "failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
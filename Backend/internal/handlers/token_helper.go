@@ -2,39 +2,139 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
 	"ticketapp/internal/services"
 )
 
+// refreshTokenTTL is how long a session survives without being refreshed.
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// refreshCookiePath scopes the refresh cookie to the auth routes that need
+// it: /auth/refresh and /auth/logout.
+const refreshCookiePath = "/auth"
+
+// errSessionGone means the token being rotated was revoked concurrently.
+var errSessionGone = errors.New("session no longer valid")
+
+// signOutEverywhere revokes all of a user's sessions and every access token
+// issued to them so far.
+func signOutEverywhere(
+	tokenRepo repositories.RefreshTokenRepository,
+	denylist services.TokenDenylist,
+	userID uuid.UUID,
+) error {
+	if err := tokenRepo.RevokeAll(userID); err != nil {
+		return err
+	}
+	return revokeUserTokens(denylist, userID)
+}
+
+// revokeUserTokens denylists every access token issued to a user so far.
+// Their sessions survive, so the next refresh issues tokens that reflect
+// the account as it is now.
+func revokeUserTokens(denylist services.TokenDenylist, userID uuid.UUID) error {
+	now := time.Now()
+	return denylist.RevokeUser(userID.String(), now, now.Add(services.AccessTokenTTL))
+}
+
+// revokeSessionTokens denylists the access tokens already issued for a
+// session, which would otherwise outlive its revoked refresh token.
+func revokeSessionTokens(denylist services.TokenDenylist, sessionID uuid.UUID) error {
+	return denylist.RevokeSession(sessionID.String(), time.Now().Add(services.AccessTokenTTL))
+}
+
+// issueTokens starts a new session for user after a completed login. amr
+// lists the authentication methods used (services.AMR*).
 func (h *AuthHandler) issueTokens(
 	w http.ResponseWriter,
-	userID string,
-	role string,
+	r *http.Request,
+	user *models.User,
+	amr ...string,
 ) {
-	// generate access token
-	accessToken, err := h.jwt.GenerateAccessToken(userID, role)
-	if err != nil {
-		http.Error(w, "token generation failed", http.StatusInternalServerError)
+	if len(amr) > 1 && !slices.Contains(amr, services.AMRMultiFactor) {
+		amr = append(amr, services.AMRMultiFactor)
+	}
+	h.writeTokens(w, r, user, nil, amr)
+}
+
+// writeTokens stores a refresh token, either as the start of a session or
+// as the rotation of prev, and sends the pair to the client.
+func (h *AuthHandler) writeTokens(
+	w http.ResponseWriter,
+	r *http.Request,
+	user *models.User,
+	prev *models.RefreshToken,
+	amr []string,
+) {
+	// every path here should have checked already; this is the backstop
+	if !user.IsActive {
+		http.Error(w, "account disabled", http.StatusForbidden)
 		return
 	}
 
 	// generate refresh token
 	refreshToken := uuid.NewString()
-	refreshHash := services.HashToken(refreshToken)
+
+	next := &models.RefreshToken{
+		UserID:    user.ID,
+		Hash:      services.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		UserAgent: r.UserAgent(),
+		IPAddress: middlewares.ClientIP(r),
+		AMR:       amr,
+	}
 
 	// store refresh token (hashed)
-	err = h.tokenRepo.Store(
-		uuid.MustParse(userID),
-		refreshHash,
-		time.Now().Add(7*24*time.Hour),
-	)
+	var err error
+	if prev == nil {
+		err = h.tokenRepo.Store(next)
+	} else {
+		err = h.tokenRepo.Rotate(prev.ID, next)
+		if errors.Is(err, repositories.ErrNotFound) {
+			err = errSessionGone
+		}
+	}
+	if errors.Is(err, errSessionGone) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		http.Error(w, "token storage failed", http.StatusInternalServerError)
 		return
 	}
+	if prev == nil {
+		recordAudit(h.auditRepo, r, models.AuditLoginSucceeded, &user.ID, map[string]any{
+			"session_id": next.SessionID,
+			"amr":        amr,
+		})
+	}
+
+	var orgID string
+	if user.OrganizationID != nil {
+		orgID = user.OrganizationID.String()
+	}
+
+	// generate access token
+	accessToken, err := h.jwt.GenerateAccessToken(services.AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID.String()},
+		Role:             user.Role,
+		OrganizationID:   orgID,
+		SessionID:        next.SessionID.String(),
+		AMR:              next.AMR,
+	})
+	if err != nil {
+		http.Error(w, "token generation failed", http.StatusInternalServerError)
+		return
+	}
 
 	// set secure cookie
 	http.SetCookie(w, &http.Cookie{
@@ -43,7 +143,7 @@ func (h *AuthHandler) issueTokens(
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
-		Path:     "/auth/refresh",
+		Path:     refreshCookiePath,
 	})
 
 	// response
@@ -52,3 +152,14 @@ func (h *AuthHandler) issueTokens(
 	})
 }
 
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     refreshCookiePath,
+		MaxAge:   -1,
+	})
+}
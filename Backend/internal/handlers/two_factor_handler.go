@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+	"ticketapp/internal/utils"
+)
+
+// Setup2FA starts TOTP enrollment. The secret stays pending until the user
+// proves possession of it via Confirm2FA.
+func (h *AuthHandler) Setup2FA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.Is2FAEnabled {
+		http.Error(w, "2fa already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, url, err := h.otp.GenerateSecret(user.Email)
+	if err != nil {
+		http.Error(w, "could not generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	qr, err := h.otp.QRCodePNG(url)
+	if err != nil {
+		http.Error(w, "could not render qr code", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.userRepo.StorePendingOTPSecret(user.ID, secret); err != nil {
+		if errors.Is(err, repositories.ErrConflict) {
+			http.Error(w, "2fa already enabled", http.StatusConflict)
+			return
+		}
+		http.Error(w, "could not store secret", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_url": url,
+		"qr_png":      base64.StdEncoding.EncodeToString(qr),
+	})
+}
+
+// Confirm2FA enables 2FA once the user submits a valid code for the pending
+// secret. The recovery codes are only ever shown in this response.
+func (h *AuthHandler) Confirm2FA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := h.userRepo.GetPendingOTPSecret(user.ID)
+	if err != nil {
+		http.Error(w, "no pending 2fa setup", http.StatusBadRequest)
+		return
+	}
+
+	step, ok := h.otp.Verify(secret, req.Code)
+	if !ok {
+		http.Error(w, "invalid otp", http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := h.newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "could not generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.userRepo.ConfirmOTPSecret(user.ID, step, hashes); err != nil {
+		http.Error(w, "could not enable 2fa", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditMFAEnabled, &user.ID, map[string]any{"method": "totp"})
+
+	writeJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+// Disable2FA turns 2FA off after re-checking the user's password.
+func (h *AuthHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.reauthenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userRepo.Disable2FA(user.ID); err != nil {
+		http.Error(w, "could not disable 2fa", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.auditRepo, r, models.AuditMFADisabled, &user.ID, map[string]any{"method": "totp"})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes invalidates every existing recovery code and
+// returns a fresh set.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.reauthenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.Is2FAEnabled {
+		http.Error(w, "2fa not enabled", http.StatusConflict)
+		return
+	}
+
+	codes, hashes, err := h.newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "could not generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.userRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		http.Error(w, "could not store recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func (h *AuthHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// reauthenticate loads the caller and checks the password in the request
+// body, for actions that weaken account security.
+func (h *AuthHandler) reauthenticate(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if utils.ComparePassword(user.PasswordHash, req.Password) != nil {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *AuthHandler) newRecoveryCodes() ([]string, []string, error) {
+	codes, err := h.otp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = services.HashToken(c)
+	}
+	return codes, hashes, nil
+}
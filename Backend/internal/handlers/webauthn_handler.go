@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+)
+
+// ceremonyHeader carries the token from a begin response into the matching
+// finish request, whose body is the raw browser credential response.
+const ceremonyHeader = "X-WebAuthn-Ceremony"
+
+func (h *AuthHandler) webauthnEnabled(w http.ResponseWriter) bool {
+	if h.webauthn == nil {
+		http.Error(w, "webauthn not configured", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// BeginWebAuthnRegistration returns options for navigator.credentials.create.
+// Adding a factor requires the current password.
+func (h *AuthHandler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	user, ok := h.reauthenticate(w, r)
+	if !ok {
+		return
+	}
+
+	token, options, err := h.webauthn.BeginRegistration(user)
+	if err != nil {
+		log.Println("begin webauthn registration:", err)
+		http.Error(w, "could not start registration", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ceremony_token": token,
+		"options":        options,
+	})
+}
+
+// FinishWebAuthnRegistration stores the credential the browser created. An
+// optional ?name= labels it in the credential list.
+func (h *AuthHandler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	cred, err := h.webauthn.FinishRegistration(
+		user,
+		r.Header.Get(ceremonyHeader),
+		r.URL.Query().Get("name"),
+		r,
+	)
+	if errors.Is(err, repositories.ErrConflict) {
+		http.Error(w, "credential already registered", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "registration failed", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, cred)
+}
+
+func (h *AuthHandler) ListWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	userID, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	creds, err := h.webauthn.ListCredentials(userID)
+	if err != nil {
+		http.Error(w, "could not list credentials", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// DeleteWebAuthnCredential removes a security key or passkey. Like adding
+// one, it requires the current password.
+func (h *AuthHandler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid credential id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := h.reauthenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webauthn.DeleteCredential(user.ID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "credential not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "could not delete credential", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginWebAuthnMFA starts a security-key assertion as the second login step,
+// in place of a TOTP code. Starting one counts as a challenge attempt.
+func (h *AuthHandler) BeginWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := h.challengeRepo.ClaimAttempt(services.HashToken(req.ChallengeToken), mfaMaxAttempts)
+	if err != nil {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(challenge.UserID)
+	if err != nil {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	token, options, err := h.webauthn.BeginMFALogin(user, challenge.ID)
+	if errors.Is(err, services.ErrNoCredentials) {
+		http.Error(w, "no security keys registered", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("begin webauthn mfa:", err)
+		http.Error(w, "could not start webauthn login", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ceremony_token": token,
+		"options":        options,
+	})
+}
+
+// FinishWebAuthnMFA completes the second login step and issues tokens.
+func (h *AuthHandler) FinishWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	user, challengeID, err := h.webauthn.FinishMFALogin(r.Header.Get(ceremonyHeader), r)
+	if errors.Is(err, services.ErrCloneWarning) {
+		recordAudit(h.auditRepo, r, models.AuditWebAuthnCloneWarning, &user.ID, nil)
+	}
+	if err != nil || !user.IsActive {
+		http.Error(w, "webauthn verification failed", http.StatusUnauthorized)
+		return
+	}
+
+	firstFactor, err := h.challengeRepo.Consume(challengeID)
+	if err != nil {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+
+	h.issueTokens(w, r, user, firstFactor, services.AMRHardwareKey)
+}
+
+// BeginPasskeyLogin starts a passwordless login.
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	token, options, err := h.webauthn.BeginPasskeyLogin()
+	if err != nil {
+		log.Println("begin passkey login:", err)
+		http.Error(w, "could not start passkey login", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ceremony_token": token,
+		"options":        options,
+	})
+}
+
+// FinishPasskeyLogin verifies a user-verified passkey assertion. That is
+// already two factors, so no OTP step follows.
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.webauthnEnabled(w) {
+		return
+	}
+
+	user, err := h.webauthn.FinishPasskeyLogin(r.Header.Get(ceremonyHeader), r)
+	if errors.Is(err, services.ErrCloneWarning) {
+		recordAudit(h.auditRepo, r, models.AuditWebAuthnCloneWarning, &user.ID, map[string]any{"passkey": true})
+	}
+	if err != nil || !user.IsActive {
+		http.Error(w, "passkey verification failed", http.StatusUnauthorized)
+		return
+	}
+
+	h.issueTokens(w, r, user, services.AMRHardwareKey, services.AMRMultiFactor)
+}
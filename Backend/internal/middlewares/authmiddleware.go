@@ -6,14 +6,30 @@ import (
 	"strings"
 
 	"ticketapp/internal/services"
+
+	"github.com/google/uuid"
 )
 
 type ctxKey string
 
-const RoleKey ctxKey = "role"
-const UserIDKey ctxKey = "user_id"
+const PrincipalKey ctxKey = "principal"
+
+// Principal is the authenticated caller, taken from a validated access token.
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+	// OrganizationID is uuid.Nil for users outside any organization.
+	OrganizationID uuid.UUID
+	SessionID      uuid.UUID
+	TokenID        string
+	// MFA is true when the login satisfied a second factor.
+	MFA    bool
+	Claims *services.AccessClaims
+}
 
-func AuthMiddleware(jwtSvc *services.JWTService) func(http.Handler) http.Handler {
+// AuthMiddleware accepts a valid bearer token that has not been revoked
+// through denylist.
+func AuthMiddleware(jwtSvc *services.JWTService, denylist services.TokenDenylist) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -31,10 +47,85 @@ func AuthMiddleware(jwtSvc *services.JWTService) func(http.Handler) http.Handler
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), RoleKey, claims["role"])
-			ctx = context.WithValue(ctx, UserIDKey, claims["sub"])
+			userID, err := uuid.Parse(claims.Subject)
+			if err != nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// optional claims stay uuid.Nil when absent or malformed
+			sessionID, _ := uuid.Parse(claims.SessionID)
+			var sid string
+			if sessionID != uuid.Nil {
+				sid = sessionID.String()
+			}
+
+			revoked, err := denylist.IsRevoked(claims.ID, claims.Subject, sid, claims.IssuedAt.Time)
+			if err != nil {
+				// fail closed: a revoked token must not slip through an outage
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if revoked {
+				http.Error(w, "token revoked", http.StatusUnauthorized)
+				return
+			}
+
+			p := &Principal{
+				UserID:  userID,
+				Role:    claims.Role,
+				TokenID: claims.ID,
+				MFA:     claims.MFA(),
+				Claims:  claims,
+			}
+			p.OrganizationID, _ = uuid.Parse(claims.OrganizationID)
+			p.SessionID = sessionID
+
+			ctx := context.WithValue(r.Context(), PrincipalKey, p)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// PrincipalFromContext returns the caller set by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(PrincipalKey).(*Principal)
+	return p, ok
+}
+
+// UserIDFromContext returns the authenticated user's id set by AuthMiddleware.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+// RoleFromContext returns the authenticated user's role set by AuthMiddleware.
+func RoleFromContext(ctx context.Context) string {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return p.Role
+}
+
+// OrganizationIDFromContext returns the caller's organization, if they belong to one.
+func OrganizationIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.OrganizationID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return p.OrganizationID, true
+}
+
+// SessionIDFromContext returns the session the access token was issued for.
+func SessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.SessionID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return p.SessionID, true
+}
@@ -0,0 +1,183 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const ClientIPKey ctxKey = "client_ip"
+
+// TrustedProxies is the set of networks whose forwarding header we believe,
+// and which header that is. The zero value trusts nobody, so headers are
+// ignored entirely.
+type TrustedProxies struct {
+	nets []*net.IPNet
+	// header is the one forwarding header the proxies set; any other is
+	// client input passed through and never read.
+	header string
+}
+
+// Forwarding headers a trusted proxy may be configured to set.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderForwarded     = "Forwarded"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// ParseTrustedProxies reads a comma-separated list of CIDRs or bare IPs,
+// e.g. "10.0.0.0/8, 192.168.1.7", and the header those proxies set
+// (X-Forwarded-For when empty).
+func ParseTrustedProxies(list, header string) (*TrustedProxies, error) {
+	t := &TrustedProxies{header: HeaderXForwardedFor}
+
+	if header != "" {
+		switch h := http.CanonicalHeaderKey(strings.TrimSpace(header)); h {
+		case HeaderXForwardedFor, HeaderForwarded:
+			t.header = h
+		case http.CanonicalHeaderKey(HeaderXRealIP):
+			t.header = HeaderXRealIP
+		default:
+			return nil, fmt.Errorf("unsupported forwarding header %q", header)
+		}
+	}
+
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			t.nets = append(t.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		t.nets = append(t.nets, n)
+	}
+
+	return t, nil
+}
+
+func (t *TrustedProxies) trusts(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP resolves the client address once per request and stores it under
+// ClientIPKey. The configured forwarding header is only consulted when the
+// direct peer is a trusted proxy, and is walked right-to-left so a client
+// can't prepend a fake hop.
+func RealIP(trusted *TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := trusted.resolve(r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClientIPKey, ip)))
+		})
+	}
+}
+
+func (t *TrustedProxies) resolve(r *http.Request) string {
+	peer := parseIP(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !t.trusts(peer) {
+		return peer.String()
+	}
+
+	var chain []string
+	switch t.header {
+	case HeaderForwarded:
+		chain = forwardedFor(r.Header.Values(HeaderForwarded))
+	default:
+		// X-Real-IP is a single address, but a client-sent copy may sit
+		// left of the proxy's, so it is walked like a list
+		chain = splitList(r.Header.Values(t.header))
+	}
+
+	// The closest untrusted hop is the client; anything left of it is
+	// whatever the client chose to send.
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseIP(chain[i])
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !t.trusts(ip) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// forwardedFor pulls the for= parameters out of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, elem := range splitList(values) {
+		for _, pair := range strings.Split(elem, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(k, "for") {
+				out = append(out, strings.Trim(v, `"`))
+			}
+		}
+	}
+	return out
+}
+
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// parseIP accepts "1.2.3.4", "1.2.3.4:80", "[::1]" and "[::1]:80".
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+// ClientIP returns the address resolved by RealIP, falling back to the
+// socket peer when the middleware isn't installed.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+
+	if ip := parseIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	const proxy = "10.0.0.1:4000"
+
+	tests := []struct {
+		name    string
+		proxies string
+		header  string // configured forwarding header
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "no trusted proxies ignores headers",
+			peer:    "203.0.113.9:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "untrusted peer sends xff",
+			proxies: "10.0.0.0/8",
+			peer:    "203.0.113.9:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "xff from trusted proxy",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client prepends a fake xff hop",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client sends its own xff line",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "chain of trusted proxies",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.2"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "forwarded header spoofed while xff is configured",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{
+				"Forwarded":       {"for=1.1.1.1"},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "x-real-ip spoofed while xff is configured and absent",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Real-Ip": {"1.1.1.1"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "garbage xff hop stops the walk at the proxy",
+			proxies: "10.0.0.0/8",
+			peer:    proxy,
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, not-an-ip"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "forwarded from trusted proxy",
+			proxies: "10.0.0.0/8",
+			header:  "forwarded",
+			peer:    proxy,
+			headers: map[string][]string{"Forwarded": {`for=1.1.1.1, for="[2001:db8::1]:4711";proto=https`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "xff spoofed while forwarded is configured",
+			proxies: "10.0.0.0/8",
+			header:  "Forwarded",
+			peer:    proxy,
+			headers: map[string][]string{
+				"Forwarded":       {"for=198.51.100.1"},
+				"X-Forwarded-For": {"1.1.1.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "x-real-ip from trusted proxy",
+			proxies: "10.0.0.0/8",
+			header:  "X-Real-IP",
+			peer:    proxy,
+			headers: map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client-sent x-real-ip left of the proxy's",
+			proxies: "10.0.0.0/8",
+			header:  "X-Real-IP",
+			peer:    proxy,
+			headers: map[string][]string{"X-Real-Ip": {"1.1.1.1", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "xff spoofed while x-real-ip is configured",
+			proxies: "10.0.0.0/8",
+			header:  "X-Real-IP",
+			peer:    proxy,
+			headers: map[string][]string{
+				"X-Real-Ip":       {"198.51.100.1"},
+				"X-Forwarded-For": {"1.1.1.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "ipv6 peer",
+			peer:    "[2001:db8::2]:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:    "2001:db8::2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := ParseTrustedProxies(tt.proxies, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer
+			for k, vs := range tt.headers {
+				for _, v := range vs {
+					r.Header.Add(k, v)
+				}
+			}
+
+			var got string
+			RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		list, header string
+		wantErr      bool
+	}{
+		{list: "", header: ""},
+		{list: "10.0.0.0/8, 192.168.1.7, ::1", header: "x-forwarded-for"},
+		{list: "10.0.0.1", header: "X-Real-IP"},
+		{list: "not-an-ip", wantErr: true},
+		{list: "10.0.0.0/33", wantErr: true},
+		{list: "10.0.0.1", header: "X-Client-IP", wantErr: true},
+		{list: "10.0.0.1", header: "True-Client-IP", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseTrustedProxies(tt.list, tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTrustedProxies(%q, %q) err = %v, wantErr %v", tt.list, tt.header, err, tt.wantErr)
+		}
+	}
+}
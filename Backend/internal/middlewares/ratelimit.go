@@ -1,92 +1,362 @@
 package middlewares
 
 import (
-	"net"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-type visitor struct {
-	lastSeen time.Time
-	count    int
+// RateDecision is a limiter's verdict on one request.
+type RateDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the quota is fully available again.
+	Reset time.Duration
+	// RetryAfter is how long a rejected caller should wait.
+	RetryAfter time.Duration
 }
 
-var (
-	visitors = make(map[string]*visitor)
-	mu       sync.Mutex
-)
+// Limiter decides whether the holder of key may make another request.
+// Refund gives back what an allowed request cost, for when another policy
+// turns it away.
+type Limiter interface {
+	Allow(key string) (RateDecision, error)
+	Refund(key string) error
+}
 
-// RateLimit limits requests per IP.
-// Default: 5 requests per 10 seconds (safe for auth endpoints)
-func RateLimit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// TokenBucketStore keeps token buckets. Take refills key's bucket at rate
+// tokens per second up to burst and removes one token if it can; Give puts
+// one back, up to burst.
+type TokenBucketStore interface {
+	Take(key string, rate float64, burst int, now time.Time) (tokens float64, allowed bool, err error)
+	Give(key string, burst int) error
+}
 
-		ip := clientIP(r)
-		now := time.Now()
+// WindowStore keeps fixed-window counters. Incr counts a hit in the window
+// containing now and returns it with the previous window's count; Decr
+// uncounts one.
+type WindowStore interface {
+	Incr(key string, window time.Duration, now time.Time) (curr, prev int, err error)
+	Decr(key string, window time.Duration, now time.Time) error
+}
 
-		mu.Lock()
-		v, exists := visitors[ip]
+// RateLimitStore holds limiter state, in process or shared between replicas.
+type RateLimitStore interface {
+	TokenBucketStore
+	WindowStore
+	// Purge drops state untouched since before.
+	Purge(before time.Time) error
+}
 
-		if !exists {
-			visitors[ip] = &visitor{
-				lastSeen: now,
-				count:    1,
-			}
-			mu.Unlock()
-			next.ServeHTTP(w, r)
-			return
-		}
+// TokenBucket allows bursts of up to limit requests, refilled evenly over per.
+type TokenBucket struct {
+	store TokenBucketStore
+	limit int
+	rate  float64
+}
 
-		// Reset window after 10 seconds
-		if now.Sub(v.lastSeen) > 10*time.Second {
-			v.lastSeen = now
-			v.count = 1
-			mu.Unlock()
-			next.ServeHTTP(w, r)
-			return
-		}
+func NewTokenBucket(store TokenBucketStore, limit int, per time.Duration) *TokenBucket {
+	return &TokenBucket{store: store, limit: limit, rate: float64(limit) / per.Seconds()}
+}
 
-		// Allow max 5 requests per window
-		if v.count >= 5 {
-			mu.Unlock()
-			http.Error(w, "too many requests", http.StatusTooManyRequests)
-			return
-		}
+func (b *TokenBucket) Allow(key string) (RateDecision, error) {
+	tokens, allowed, err := b.store.Take(key, b.rate, b.limit, time.Now())
+	if err != nil {
+		return RateDecision{}, err
+	}
 
-		v.count++
-		mu.Unlock()
+	d := RateDecision{
+		Allowed:   allowed,
+		Limit:     b.limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(b.limit) - tokens) / b.rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / b.rate)
+	}
+	return d, nil
+}
 
-		next.ServeHTTP(w, r)
-	})
+func (b *TokenBucket) Refund(key string) error {
+	return b.store.Give(key, b.limit)
 }
 
-// Cleanup stale IPs (call once at startup)
-func StartRateLimitCleanup() {
-	go func() {
-		for {
-			time.Sleep(1 * time.Minute)
-			mu.Lock()
-			for ip, v := range visitors {
-				if time.Since(v.lastSeen) > 5*time.Minute {
-					delete(visitors, ip)
+// SlidingWindow allows limit requests in any window-long span, estimated
+// from the current and previous fixed windows.
+type SlidingWindow struct {
+	store  WindowStore
+	limit  int
+	window time.Duration
+}
+
+func NewSlidingWindow(store WindowStore, limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{store: store, limit: limit, window: window}
+}
+
+func (s *SlidingWindow) Allow(key string) (RateDecision, error) {
+	now := time.Now()
+
+	curr, prev, err := s.store.Incr(key, s.window, now)
+	if err != nil {
+		return RateDecision{}, err
+	}
+
+	elapsed := now.Sub(now.Truncate(s.window))
+	weight := 1 - float64(elapsed)/float64(s.window)
+	used := float64(prev)*weight + float64(curr)
+
+	d := RateDecision{
+		Allowed:   used <= float64(s.limit),
+		Limit:     s.limit,
+		Remaining: max(s.limit-int(math.Ceil(used)), 0),
+		Reset:     s.window - elapsed,
+	}
+	if !d.Allowed {
+		d.RetryAfter = d.Reset
+	}
+	return d, nil
+}
+
+func (s *SlidingWindow) Refund(key string) error {
+	return s.store.Decr(key, s.window, time.Now())
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// RateKeyFunc extracts what a policy limits by. ok=false skips the policy
+// for this request.
+type RateKeyFunc func(r *http.Request) (key string, ok bool)
+
+// ByIP limits per client address.
+func ByIP(r *http.Request) (string, bool) {
+	return ClientIP(r), true
+}
+
+// ByUserID limits per authenticated user; use behind AuthMiddleware.
+func ByUserID(r *http.Request) (string, bool) {
+	id, ok := UserIDFromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	return id.String(), true
+}
+
+// ByEmail limits per "email" field of a JSON body, so one account cannot be
+// hammered from many addresses. The body is restored for the handler.
+func ByEmail(r *http.Request) (string, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return "", false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Email == "" {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email)), true
+}
+
+// RateLimitPolicy applies Limiter to every request, keyed by Key. Name
+// namespaces the key so policies sharing a store don't collide.
+type RateLimitPolicy struct {
+	Name    string
+	Limiter Limiter
+	Key     RateKeyFunc
+}
+
+// Limit enforces all policies and reports the tightest one in the
+// RateLimit-Limit/-Remaining/-Reset headers, with Retry-After on 429s.
+// A request one policy rejects is refunded to the policies that allowed it,
+// so it costs nothing against them. Store failures let the request through
+// rather than lock everyone out.
+func Limit(policies ...RateLimitPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tightest *RateDecision
+			var charged []rateCharge
+
+			for _, p := range policies {
+				key, ok := p.Key(r)
+				if !ok {
+					continue
+				}
+				key = p.Name + ":" + key
+
+				d, err := p.Limiter.Allow(key)
+				if err != nil {
+					log.Printf("rate limit %s: %v", p.Name, err)
+					continue
+				}
+
+				if !d.Allowed {
+					for _, c := range charged {
+						if err := c.policy.Limiter.Refund(c.key); err != nil {
+							log.Printf("rate limit %s: refund: %v", c.policy.Name, err)
+						}
+					}
+					writeRateHeaders(w, d)
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+					http.Error(w, "too many requests", http.StatusTooManyRequests)
+					return
+				}
+				charged = append(charged, rateCharge{p, key})
+
+				if tightest == nil || d.Remaining < tightest.Remaining {
+					tightest = &d
 				}
 			}
-			mu.Unlock()
-		}
-	}()
+
+			if tightest != nil {
+				writeRateHeaders(w, *tightest)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateCharge is a policy that counted the request under key.
+type rateCharge struct {
+	policy RateLimitPolicy
+	key    string
+}
+
+func writeRateHeaders(w http.ResponseWriter, d RateDecision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps limiter state in process. It is lost on restart
+// and not shared between replicas.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	windows map[string]*window
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type window struct {
+	start      time.Time
+	curr, prev int
 }
 
-// Extract real client IP (supports proxies)
-func clientIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		return xff
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+		windows: make(map[string]*window),
 	}
+}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+func (m *MemoryRateLimitStore) Take(key string, rate float64, burst int, now time.Time) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+func (m *MemoryRateLimitStore) Give(key string, burst int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.buckets[key]; ok {
+		b.tokens = math.Min(float64(burst), b.tokens+1)
+	}
+	return nil
+}
+
+func (m *MemoryRateLimitStore) Incr(key string, size time.Duration, now time.Time) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := now.Truncate(size)
+
+	w, ok := m.windows[key]
+	switch {
+	case !ok:
+		w = &window{start: start}
+		m.windows[key] = w
+	case w.start.Equal(start):
+	case w.start.Equal(start.Add(-size)):
+		w.start, w.prev, w.curr = start, w.curr, 0
+	default:
+		w.start, w.prev, w.curr = start, 0, 0
+	}
+
+	w.curr++
+	return w.curr, w.prev, nil
+}
+
+func (m *MemoryRateLimitStore) Decr(key string, size time.Duration, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if w, ok := m.windows[key]; ok && w.start.Equal(now.Truncate(size)) && w.curr > 0 {
+		w.curr--
+	}
+	return nil
+}
+
+func (m *MemoryRateLimitStore) Purge(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, b := range m.buckets {
+		if b.updated.Before(before) {
+			delete(m.buckets, k)
+		}
 	}
-	return ip
+	for k, w := range m.windows {
+		if w.start.Before(before) {
+			delete(m.windows, k)
+		}
+	}
+	return nil
+}
+
+// StartRateLimitCleanup periodically drops limiter state idle for a day;
+// no policy looks further back than that.
+func StartRateLimitCleanup(store RateLimitStore, interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			if err := store.Purge(time.Now().Add(-24 * time.Hour)); err != nil {
+				log.Println("rate limit cleanup:", err)
+			}
+		}
+	}()
 }
@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimitRefundsWhenALaterPolicyRejects(t *testing.T) {
+	const requests = 4
+
+	tests := []struct {
+		name  string
+		first func(store RateLimitStore) Limiter
+		// used reads how much of the first policy's quota was spent
+		used func(store *MemoryRateLimitStore) float64
+	}{
+		{
+			name:  "token bucket",
+			first: func(s RateLimitStore) Limiter { return NewTokenBucket(s, 10, time.Hour) },
+			used: func(s *MemoryRateLimitStore) float64 {
+				return 10 - s.buckets["first:k"].tokens
+			},
+		},
+		{
+			name:  "sliding window",
+			first: func(s RateLimitStore) Limiter { return NewSlidingWindow(s, 10, time.Hour) },
+			used: func(s *MemoryRateLimitStore) float64 {
+				return float64(s.windows["first:k"].curr)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryRateLimitStore()
+			key := func(*http.Request) (string, bool) { return "k", true }
+
+			h := Limit(
+				RateLimitPolicy{Name: "first", Limiter: tt.first(store), Key: key},
+				RateLimitPolicy{Name: "second", Limiter: NewTokenBucket(store, 1, time.Hour), Key: key},
+			)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			var codes []int
+			for range requests {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+				codes = append(codes, rec.Code)
+			}
+
+			if codes[0] != http.StatusOK {
+				t.Fatalf("first request: %d, want 200", codes[0])
+			}
+			for i, c := range codes[1:] {
+				if c != http.StatusTooManyRequests {
+					t.Errorf("request %d: %d, want 429", i+2, c)
+				}
+			}
+
+			// only the request that got through counts against the first policy
+			if used := tt.used(store); used < 0.99 || used > 1.01 {
+				t.Errorf("first policy charged %.2f, want 1", used)
+			}
+		})
+	}
+}
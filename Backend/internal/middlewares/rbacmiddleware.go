@@ -2,12 +2,14 @@ package middlewares
 
 import (
 	"net/http"
+	"slices"
 )
 
-func RequireRole(role string) func(http.Handler) http.Handler {
+// RequireRole allows the request through only if the caller has one of roles.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Context().Value("role") != role {
+			if !slices.Contains(roles, RoleFromContext(r.Context())) {
 				http.Error(w, "forbidden", 403)
 				return
 			}
@@ -0,0 +1,7 @@
+package models
+
+// Ticket auto-assignment strategies
+const (
+	AssignRoundRobin  = "round_robin"
+	AssignLeastLoaded = "least_loaded"
+)
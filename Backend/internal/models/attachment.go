@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a file uploaded to a ticket, optionally tied to a comment.
+// The bytes live in blob storage under StorageKey.
+type Attachment struct {
+	ID          uuid.UUID  `json:"id"`
+	TicketID    uuid.UUID  `json:"ticket_id"`
+	CommentID   *uuid.UUID `json:"comment_id,omitempty"`
+	UploaderID  uuid.UUID  `json:"uploader_id"`
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	StorageKey  string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
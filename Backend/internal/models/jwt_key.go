@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// JWTKey is an access-token signing key. After ExpiresAt nothing it signed
+// can still be valid, so it is no longer published or accepted.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	// PrivateKey is PKCS#8 DER sealed with the key-encryption key KEKID, or
+	// plaintext when KEKID is empty (rows written before encryption).
+	PrivateKey []byte
+	KEKID      string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+)
+
+// LoginFailure counts recent failed password logins for one account (keyed
+// by normalized email) or one client IP.
+type LoginFailure struct {
+	Scope        string     `json:"scope"`
+	Key          string     `json:"key"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
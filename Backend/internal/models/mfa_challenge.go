@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAChallenge is issued by a successful password check (or SSO login) on
+// an account with 2FA enabled. Only its hash is stored; the raw token goes
+// to the client.
+type MFAChallenge struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// FirstFactor is the amr value of the step that issued the challenge.
+	FirstFactor string
+	Attempts    int
+	ExpiresAt   time.Time
+	ConsumedAt  *time.Time
+}
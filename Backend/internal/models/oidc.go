@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// OIDCLoginState is what the callback of an authorization-code login needs
+// to finish it; it is looked up by the hash of the state parameter.
+type OIDCLoginState struct {
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
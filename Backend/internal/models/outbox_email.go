@@ -0,0 +1,14 @@
+package models
+
+import "github.com/google/uuid"
+
+// OutboxEmail is a queued email awaiting delivery.
+type OutboxEmail struct {
+	ID       uuid.UUID
+	To       string
+	ReplyTo  string
+	Subject  string
+	Text     string
+	HTML     string
+	Attempts int
+}
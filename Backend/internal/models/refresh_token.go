@@ -1,9 +1,34 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type RefreshToken struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
 	Hash   string
+	// SessionID is shared by every token rotated from the same login.
+	SessionID        uuid.UUID
+	ExpiresAt        time.Time
+	UserAgent        string
+	IPAddress        string
+	SessionStartedAt time.Time
+	// AMR records how the session's login was authenticated.
+	AMR       []string
+	Revoked   bool
+	RevokedAt *time.Time
+}
+
+// Session is a signed-in device as shown to the user.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "github.com/google/uuid"
+
+// SLA states reported on ticket reads
+const (
+	SLAStateOnTrack  = "on_track"
+	SLAStateAtRisk   = "at_risk"
+	SLAStateBreached = "breached"
+	SLAStatePaused   = "paused"
+	SLAStateMet      = "met"
+)
+
+// SLAPolicy sets response targets for one priority. A nil OrganizationID is
+// the default policy used when an organization has no override.
+type SLAPolicy struct {
+	ID                   uuid.UUID  `json:"id"`
+	OrganizationID       *uuid.UUID `json:"organization_id,omitempty"`
+	Priority             string     `json:"priority"`
+	FirstResponseMinutes int        `json:"first_response_minutes"`
+	ResolutionMinutes    int        `json:"resolution_minutes"`
+}
+
+// SLAStatus is the computed state of a ticket's deadlines.
+type SLAStatus struct {
+	FirstResponse string `json:"first_response"`
+	Resolution    string `json:"resolution"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ticket statuses
+const (
+	TicketStatusOpen              = "open"
+	TicketStatusTriaged           = "triaged"
+	TicketStatusInProgress        = "in_progress"
+	TicketStatusWaitingOnCustomer = "waiting_on_customer"
+	TicketStatusResolved          = "resolved"
+	TicketStatusClosed            = "closed"
+)
+
+// Ticket priorities
+const (
+	TicketPriorityLow    = "low"
+	TicketPriorityNormal = "normal"
+	TicketPriorityHigh   = "high"
+	TicketPriorityUrgent = "urgent"
+)
+
+type Ticket struct {
+	ID          uuid.UUID  `json:"id"`
+	Subject     string     `json:"subject"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	RequesterID uuid.UUID  `json:"requester_id"`
+	AssigneeID  *uuid.UUID `json:"assignee_id,omitempty"`
+
+	// OrganizationID is copied from the requester when the ticket is opened.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// SLA
+	FirstResponseDue      *time.Time `json:"first_response_due,omitempty"`
+	ResolutionDue         *time.Time `json:"resolution_due,omitempty"`
+	FirstRespondedAt      *time.Time `json:"first_responded_at,omitempty"`
+	ResolvedAt            *time.Time `json:"resolved_at,omitempty"`
+	SLAPausedAt           *time.Time `json:"sla_paused_at,omitempty"`
+	SLAPausedSeconds      int64      `json:"-"`
+	FirstResponseBreached bool       `json:"-"`
+	ResolutionBreached    bool       `json:"-"`
+	SLA                   *SLAStatus `json:"sla,omitempty"`
+}
+
+// ValidTicketPriority reports whether p is a known priority.
+func ValidTicketPriority(p string) bool {
+	switch p {
+	case TicketPriorityLow, TicketPriorityNormal, TicketPriorityHigh, TicketPriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// ValidTicketStatus reports whether s is a known status.
+func ValidTicketStatus(s string) bool {
+	switch s {
+	case TicketStatusOpen, TicketStatusTriaged, TicketStatusInProgress,
+		TicketStatusWaitingOnCustomer, TicketStatusResolved, TicketStatusClosed:
+		return true
+	}
+	return false
+}
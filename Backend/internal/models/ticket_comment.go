@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TicketComment is a reply on a ticket. Internal comments are staff-only
+// notes and are never shown to customers.
+type TicketComment struct {
+	ID        uuid.UUID  `json:"id"`
+	TicketID  uuid.UUID  `json:"ticket_id"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	AuthorID  uuid.UUID  `json:"author_id"`
+	Body      string     `json:"body"`
+	Internal  bool       `json:"internal"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -2,6 +2,13 @@ package models
 
 import "github.com/google/uuid"
 
+// User roles
+const (
+	RoleAdmin    = "admin"
+	RoleSupport  = "support"
+	RoleCustomer = "customer"
+)
+
 type User struct {
 	ID                    uuid.UUID
 	Email                 string
@@ -11,5 +18,7 @@ type User struct {
 	IsActive              bool
 	Is2FAEnabled           bool
 	PasswordResetRequired bool
+	IsAvailable           bool // support agents: accepting new tickets
+	OrganizationID        *uuid.UUID
 }
 
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user.
+// Data holds the verified credential record as JSON.
+type WebAuthnCredential struct {
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"-"`
+	CredentialID []byte     `json:"-"`
+	Name         string     `json:"name"`
+	Data         []byte     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+const (
+	WebAuthnCeremonyRegister = "register"
+	// WebAuthnCeremonyMFA is an assertion used as the second login step.
+	WebAuthnCeremonyMFA = "mfa"
+	// WebAuthnCeremonyPasskey is a passwordless, discoverable-credential login.
+	WebAuthnCeremonyPasskey = "passkey"
+)
+
+// WebAuthnCeremony is the server half of a registration or login ceremony,
+// looked up by the hash of the token handed to the client.
+type WebAuthnCeremony struct {
+	ID             uuid.UUID
+	Kind           string
+	UserID         *uuid.UUID
+	MFAChallengeID *uuid.UUID
+	Session        []byte
+	ExpiresAt      time.Time
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxTicketListLimit caps how many rows one List call returns.
+const MaxTicketListLimit = 100
+
+// TicketCursor marks a position in a listing ordered by (created_at, id)
+// descending. Keyset pagination stays fast and stable on large queues where
+// OFFSET would rescan and skip rows as tickets are added.
+type TicketCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c TicketCursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeTicketCursor parses a token produced by TicketCursor.Encode.
+func DecodeTicketCursor(token string) (*TicketCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &TicketCursor{CreatedAt: time.Unix(0, n), ID: uid}, nil
+}
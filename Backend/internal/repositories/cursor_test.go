@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTicketCursorRoundTrip(t *testing.T) {
+	id := uuid.MustParse("6f1c2e1a-3b4d-4e5f-8a9b-0c1d2e3f4a5b")
+
+	tests := []struct {
+		name string
+		at   time.Time
+	}{
+		{"nanosecond precision", time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)},
+		{"unix epoch", time.Unix(0, 0)},
+		{"before epoch", time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := TicketCursor{CreatedAt: tt.at, ID: id}.Encode()
+
+			got, err := DecodeTicketCursor(token)
+			if err != nil {
+				t.Fatalf("DecodeTicketCursor(%q): %v", token, err)
+			}
+			if !got.CreatedAt.Equal(tt.at) || got.ID != id {
+				t.Errorf("round trip = {%v %v}, want {%v %v}", got.CreatedAt, got.ID, tt.at, id)
+			}
+		})
+	}
+}
+
+func TestDecodeTicketCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("1|6f1c2e1a-3b4d-4e5f-8a9b-0c1d2e3f4a5b"))},
+		{"no separator", enc("1700000000")},
+		{"bad timestamp", enc("yesterday|6f1c2e1a-3b4d-4e5f-8a9b-0c1d2e3f4a5b")},
+		{"bad id", enc("1700000000|not-a-uuid")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodeTicketCursor(tt.token); err == nil {
+				t.Errorf("DecodeTicketCursor(%q) = %+v, want an error", tt.token, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package repositories
+
+import "errors"
+
+// ErrNotFound is returned when the requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a row changed underneath a conditional update.
+var ErrConflict = errors.New("conflict")
@@ -10,23 +10,265 @@ import (
 type UserRepository interface {
 	GetByUsername(username string) (*models.User, error)
 	GetByID(id uuid.UUID) (*models.User, error)
+	// GetByEmail matches email case-insensitively.
 	GetByEmail(email string) (*models.User, error)
 
+	// Create gives ErrConflict if the email or username is taken.
 	Create(user models.User) error
 	Disable(userID uuid.UUID) error
+	// SetRole changes a user's role; staff roles drop any organization.
+	SetRole(userID uuid.UUID, role string) error
+	SetOrganization(userID uuid.UUID, orgID *uuid.UUID) error
+
+	// Support agent assignment
+	SetAvailability(userID uuid.UUID, available bool) error
+	PickAgent(strategy string) (uuid.UUID, error)
 
 	// 2FA
 	GetOTPSecret(userID uuid.UUID) (string, error)
+	StorePendingOTPSecret(userID uuid.UUID, secret string) error
+	GetPendingOTPSecret(userID uuid.UUID) (string, error)
+	// ConfirmOTPSecret activates the pending secret, enables 2FA and
+	// replaces the user's recovery codes in one step.
+	ConfirmOTPSecret(userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	// AcceptOTPStep records a successfully verified TOTP time-step. It gives
+	// ErrConflict if that step (or a later one) was already used.
+	AcceptOTPStep(userID uuid.UUID, step int64) error
+	Disable2FA(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error
+	// UseRecoveryCode burns an unused code; ErrNotFound if there is none.
+	UseRecoveryCode(userID uuid.UUID, hash string) error
 
 	// Password reset
 	StoreResetToken(userID uuid.UUID, hash string, exp time.Time) error
-	ValidateResetToken(hash string) (uuid.UUID, error)
+	// ConsumeResetToken redeems an unexpired token and deletes every reset
+	// token the user holds, so none can be replayed.
+	ConsumeResetToken(hash string) (uuid.UUID, error)
 	UpdatePassword(userID uuid.UUID, passwordHash string) error
 }
 
 type RefreshTokenRepository interface {
-	Store(userID uuid.UUID, hash string, exp time.Time) error
+	// Store starts a new session; it fills in ID, SessionID and SessionStartedAt.
+	Store(t *models.RefreshToken) error
+	// Rotate replaces oldID with next inside the same session.
+	Rotate(oldID uuid.UUID, next *models.RefreshToken) error
 	GetValid(hash string) (*models.RefreshToken, error)
+	// GetByHash also returns revoked and expired tokens; ErrNotFound if the
+	// hash was never issued.
+	GetByHash(hash string) (*models.RefreshToken, error)
 	Revoke(tokenID uuid.UUID) error
 	RevokeAll(userID uuid.UUID) error
+
+	ListSessions(userID uuid.UUID) ([]models.Session, error)
+	RevokeSession(userID, sessionID uuid.UUID) error
+	RevokeOtherSessions(userID, keepSessionID uuid.UUID) error
+}
+
+// TicketFilter narrows a ticket listing. Zero values are ignored.
+type TicketFilter struct {
+	RequesterID *uuid.UUID
+	AssigneeID  *uuid.UUID
+	Status      string
+	Priority    string
+	Limit       int
+
+	// Query is matched with full-text search against the subject,
+	// description and public comments.
+	Query       string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+
+	// After continues a listing from the last ticket of the previous page.
+	After *TicketCursor
+}
+
+// TicketScope limits a TicketRepository to one tenant's tickets. The zero
+// value is unrestricted and is meant for staff only.
+type TicketScope struct {
+	OrganizationID *uuid.UUID
+	// RequesterID scopes customers who do not belong to an organization
+	// to the tickets they opened themselves.
+	RequesterID *uuid.UUID
+}
+
+// ScopeFor returns the ticket scope for a user: staff are unrestricted,
+// customers see their organization's tickets or, outside any organization,
+// only their own.
+func ScopeFor(role string, userID uuid.UUID, orgID *uuid.UUID) TicketScope {
+	switch {
+	case role == models.RoleSupport || role == models.RoleAdmin:
+		return TicketScope{}
+	case orgID != nil:
+		return TicketScope{OrganizationID: orgID}
+	}
+	return TicketScope{RequesterID: &userID}
+}
+
+type TicketRepository interface {
+	// Scoped returns a repository whose reads and writes are restricted to
+	// scope; tickets outside it behave as if they did not exist.
+	Scoped(scope TicketScope) TicketRepository
+
+	Create(ticket *models.Ticket) error
+	GetByID(id uuid.UUID) (*models.Ticket, error)
+	List(filter TicketFilter) ([]models.Ticket, error)
+	Update(ticket *models.Ticket) error
+	Delete(id uuid.UUID) error
+
+	// Status workflow
+	Transition(change *models.TicketStatusChange) (*models.Ticket, error)
+	ListStatusHistory(ticketID uuid.UUID) ([]models.TicketStatusChange, error)
+
+	// SLA
+	MarkFirstResponse(ticketID uuid.UUID) error
+	FlagSLABreaches(now time.Time) ([]uuid.UUID, error)
+}
+
+type CommentRepository interface {
+	Create(comment *models.TicketComment) error
+	GetByID(id uuid.UUID) (*models.TicketComment, error)
+	// ListByTicket returns comments oldest first; internal notes are
+	// omitted unless includeInternal is set.
+	ListByTicket(ticketID uuid.UUID, includeInternal bool) ([]models.TicketComment, error)
+}
+
+type AttachmentRepository interface {
+	Create(attachment *models.Attachment) error
+	GetByID(id uuid.UUID) (*models.Attachment, error)
+	// ListByTicket omits attachments on internal comments unless
+	// includeInternal is set.
+	ListByTicket(ticketID uuid.UUID, includeInternal bool) ([]models.Attachment, error)
+}
+
+type SLAPolicyRepository interface {
+	List() ([]models.SLAPolicy, error)
+	Upsert(policy *models.SLAPolicy) error
+	Delete(id uuid.UUID) error
+	// Resolve returns the organization's policy for priority, falling back
+	// to the default policy.
+	Resolve(organizationID *uuid.UUID, priority string) (*models.SLAPolicy, error)
+}
+
+type OrganizationRepository interface {
+	Create(org *models.Organization) error
+	GetByID(id uuid.UUID) (*models.Organization, error)
+	List() ([]models.Organization, error)
+}
+
+type MFAChallengeRepository interface {
+	// Create stores a challenge; firstFactor is the amr value of the login
+	// step that issued it.
+	Create(userID uuid.UUID, hash, firstFactor string, expiresAt time.Time) error
+	// ClaimAttempt counts one verification attempt against a live challenge.
+	// Expired, consumed or exhausted challenges give ErrNotFound.
+	ClaimAttempt(hash string, maxAttempts int) (*models.MFAChallenge, error)
+	// Consume marks the challenge used and returns its first factor;
+	// ErrNotFound if it already was.
+	Consume(id uuid.UUID) (string, error)
+}
+
+type WebAuthnRepository interface {
+	ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	CreateCredential(c *models.WebAuthnCredential) error
+	// TouchCredential stores the updated record (sign count, flags) after
+	// a successful assertion.
+	TouchCredential(credentialID []byte, data []byte) error
+	DeleteCredential(userID, id uuid.UUID) error
+
+	CreateCeremony(hash string, c *models.WebAuthnCeremony) error
+	// TakeCeremony deletes and returns an unexpired ceremony of kind, so each
+	// can only be finished once.
+	TakeCeremony(hash, kind string) (*models.WebAuthnCeremony, error)
+	// PurgeCeremonies drops ceremonies that expired before before.
+	PurgeCeremonies(before time.Time) error
+}
+
+type JWTKeyRepository interface {
+	// ListJWTKeys returns unexpired keys, newest first.
+	ListJWTKeys() ([]models.JWTKey, error)
+	CreateJWTKey(k *models.JWTKey) error
+	// SealJWTKey replaces a legacy plaintext private key with its sealed form.
+	SealJWTKey(kid string, privateKey []byte, kekID string) error
+	PurgeJWTKeys() error
+}
+
+type OIDCRepository interface {
+	CreateLoginState(stateHash string, s *models.OIDCLoginState) error
+	// TakeLoginState deletes and returns an unexpired state; ErrNotFound
+	// if it is unknown, expired or was already used.
+	TakeLoginState(stateHash string) (*models.OIDCLoginState, error)
+
+	// IdentityUser returns the user linked to an IdP subject.
+	IdentityUser(issuer, subject string) (uuid.UUID, error)
+	// LinkIdentity binds a user to an IdP subject; ErrConflict if the user
+	// is already bound to a different subject at that issuer.
+	LinkIdentity(userID uuid.UUID, issuer, subject string) error
+}
+
+type InboundMailRepository interface {
+	// Claim records a message id before ingestion; false means another
+	// delivery already claimed it.
+	Claim(messageID string) (bool, error)
+	// Release drops a claim whose ingestion failed, so a redelivery can retry.
+	Release(messageID string) error
+	SetTicket(messageID string, ticketID uuid.UUID) error
+}
+
+type EmailOutboxRepository interface {
+	Enqueue(email *models.OutboxEmail) error
+	// ClaimDue leases up to limit due emails for lease, so another worker
+	// won't pick them up while they are being sent.
+	ClaimDue(limit int, lease time.Duration) ([]models.OutboxEmail, error)
+	// MarkSent clears the bodies, which may hold live reset or invite links.
+	MarkSent(id uuid.UUID) error
+	// MarkFailed records a failed attempt and schedules the next one, or
+	// gives up on the email and clears its bodies when nextAttempt is nil.
+	MarkFailed(id uuid.UUID, lastErr string, nextAttempt *time.Time) error
+}
+
+type LoginFailureRepository interface {
+	// GetLoginFailure returns ErrNotFound when nothing has failed for key.
+	GetLoginFailure(scope, key string) (*models.LoginFailure, error)
+	// RecordLoginFailure counts one failure at now. The count starts over
+	// when the previous failure is older than since or a lock has expired.
+	RecordLoginFailure(scope, key string, now, since time.Time) (*models.LoginFailure, error)
+	LockLogin(scope, key string, until time.Time) error
+	// ClearLoginFailures forgets key; ErrNotFound if there was nothing.
+	ClearLoginFailures(scope, key string) error
+	// ListLockedLogins returns entries locked at now, latest lock first.
+	ListLockedLogins(now time.Time) ([]models.LoginFailure, error)
+	// PurgeLoginFailures drops unlocked entries last failed before before.
+	PurgeLoginFailures(before time.Time) error
+}
+
+// AuditFilter narrows an audit listing; zero fields match everything.
+type AuditFilter struct {
+	UserID  *uuid.UUID
+	ActorID *uuid.UUID
+	Action  models.AuditAction
+	IP      string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+
+	// BeforeID continues a listing from the last event of the previous page.
+	BeforeID int64
+}
+
+type AuditRepository interface {
+	// Log appends e to the hash chain, filling in ID, CreatedAt, PrevHash
+	// and Hash.
+	Log(e *models.AuditEvent) error
+	// List returns matching events newest first.
+	List(filter AuditFilter) ([]models.AuditEvent, error)
+	// ListChain returns up to limit events with id > afterID, oldest first.
+	ListChain(afterID int64, limit int) ([]models.AuditEvent, error)
+	// ChainHead returns the newest chained event; ErrNotFound if none.
+	ChainHead() (*models.AuditEvent, error)
+
+	CreateCheckpoint(c *models.AuditCheckpoint) error
+	// LatestCheckpoint returns the newest checkpoint; ErrNotFound if none.
+	LatestCheckpoint() (*models.AuditCheckpoint, error)
+	// ListCheckpoints returns every checkpoint, oldest first.
+	ListCheckpoints() ([]models.AuditCheckpoint, error)
 }
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const attachmentColumns = `a.id, a.ticket_id, a.comment_id, a.uploader_id, a.filename,
+	a.content_type, a.size, a.storage_key, a.created_at`
+
+type PostgresAttachmentRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAttachmentRepo(db *pgxpool.Pool) *PostgresAttachmentRepo {
+	return &PostgresAttachmentRepo{db: db}
+}
+
+func scanAttachment(row pgx.Row) (*models.Attachment, error) {
+	a := &models.Attachment{}
+	err := row.Scan(
+		&a.ID,
+		&a.TicketID,
+		&a.CommentID,
+		&a.UploaderID,
+		&a.Filename,
+		&a.ContentType,
+		&a.Size,
+		&a.StorageKey,
+		&a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *PostgresAttachmentRepo) Create(attachment *models.Attachment) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO attachments
+		   (id, ticket_id, comment_id, uploader_id, filename, content_type, size, storage_key)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		 RETURNING created_at`,
+		attachment.ID,
+		attachment.TicketID,
+		attachment.CommentID,
+		attachment.UploaderID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.StorageKey,
+	).Scan(&attachment.CreatedAt)
+}
+
+func (r *PostgresAttachmentRepo) GetByID(id uuid.UUID) (*models.Attachment, error) {
+	a, err := scanAttachment(r.db.QueryRow(
+		context.Background(),
+		`SELECT `+attachmentColumns+` FROM attachments a WHERE a.id=$1`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (r *PostgresAttachmentRepo) ListByTicket(
+	ticketID uuid.UUID,
+	includeInternal bool,
+) ([]models.Attachment, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT `+attachmentColumns+`
+		 FROM attachments a
+		 LEFT JOIN ticket_comments c ON c.id = a.comment_id
+		 WHERE a.ticket_id=$1 AND (c.internal IS NOT TRUE OR $2)
+		 ORDER BY a.created_at`,
+		ticketID, includeInternal,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+
+	return attachments, rows.Err()
+}
@@ -0,0 +1,299 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+// auditChainLock serializes appends so every entry links to the one
+// inserted just before it.
+const auditChainLock = 7_260_001
+
+// Caps, in bytes, on request-supplied audit strings; longer ones are cut.
+const (
+	auditMaxIP        = 64
+	auditMaxUserAgent = 512
+	auditMaxDetail    = 256
+)
+
+const auditColumns = `id, user_id, actor_id, action, COALESCE(ip, ''), COALESCE(user_agent, ''),
+	details, created_at, COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+type PostgresAuditRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAuditRepo(db *pgxpool.Pool) *PostgresAuditRepo {
+	return &PostgresAuditRepo{db: db}
+}
+
+func (r *PostgresAuditRepo) Log(e *models.AuditEvent) error {
+	// cleaned before hashing, so the hash covers exactly what is stored
+	e.IP = auditString(e.IP, auditMaxIP)
+	e.UserAgent = auditString(e.UserAgent, auditMaxUserAgent)
+	e.Details = auditDetails(e.Details)
+
+	details, err := json.Marshal(e.Details)
+	if err != nil {
+		return err
+	}
+	if e.Details == nil {
+		details = []byte("{}")
+	}
+
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`SELECT COALESCE(hash, '') FROM audit_logs ORDER BY id DESC LIMIT 1`,
+	).Scan(&e.PrevHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		e.PrevHash = ""
+	} else if err != nil {
+		return err
+	}
+
+	// Postgres keeps microseconds; hash exactly what will be read back
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if e.Hash, err = e.ComputeHash(); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO audit_logs
+		     (user_id, actor_id, action, ip, user_agent, details, created_at, prev_hash, hash)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		 RETURNING id`,
+		e.UserID, e.ActorID, string(e.Action), e.IP, e.UserAgent, string(details),
+		e.CreatedAt, e.PrevHash, e.Hash,
+	).Scan(&e.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// auditString makes a request-supplied string storable: invalid UTF-8 and
+// NUL, which Postgres text and jsonb reject, become U+FFFD, and the result
+// is cut to at most limit bytes on a rune boundary.
+func auditString(s string, limit int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	s = strings.ReplaceAll(s, "\x00", "\uFFFD")
+	if len(s) <= limit {
+		return s
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// auditDetails returns a copy of details with every string value, nested
+// ones included, passed through auditString.
+func auditDetails(details map[string]any) map[string]any {
+	if details == nil {
+		return nil
+	}
+
+	out := make(map[string]any, len(details))
+	for k, v := range details {
+		out[auditString(k, auditMaxDetail)] = auditValue(v)
+	}
+	return out
+}
+
+func auditValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return auditString(v, auditMaxDetail)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = auditString(s, auditMaxDetail)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = auditValue(e)
+		}
+		return out
+	case map[string]any:
+		return auditDetails(v)
+	default:
+		return v
+	}
+}
+
+func (r *PostgresAuditRepo) List(filter AuditFilter) ([]models.AuditEvent, error) {
+	var (
+		where []string
+		args  []any
+	)
+
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.UserID != nil {
+		add("user_id=$%d", *filter.UserID)
+	}
+	if filter.ActorID != nil {
+		add("actor_id=$%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action=$%d", string(filter.Action))
+	}
+	if filter.IP != "" {
+		add("ip=$%d", filter.IP)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		add("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_logs`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 200
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	return r.query(query, args...)
+}
+
+func (r *PostgresAuditRepo) ListChain(afterID int64, limit int) ([]models.AuditEvent, error) {
+	return r.query(
+		`SELECT `+auditColumns+` FROM audit_logs WHERE id > $1 ORDER BY id LIMIT $2`,
+		afterID, limit,
+	)
+}
+
+func (r *PostgresAuditRepo) ChainHead() (*models.AuditEvent, error) {
+	events, err := r.query(
+		`SELECT ` + auditColumns + ` FROM audit_logs WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+	return &events[0], nil
+}
+
+func (r *PostgresAuditRepo) query(sql string, args ...any) ([]models.AuditEvent, error) {
+	rows, err := r.db.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var (
+			e       models.AuditEvent
+			action  string
+			details []byte
+		)
+		if err := rows.Scan(
+			&e.ID, &e.UserID, &e.ActorID, &action, &e.IP, &e.UserAgent,
+			&details, &e.CreatedAt, &e.PrevHash, &e.Hash,
+		); err != nil {
+			return nil, err
+		}
+		e.Action = models.AuditAction(action)
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *PostgresAuditRepo) CreateCheckpoint(c *models.AuditCheckpoint) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO audit_checkpoints (last_event_id, last_hash, key_id, signature, created_at)
+		 VALUES ($1,$2,$3,$4,$5)
+		 RETURNING id`,
+		c.LastEventID, c.LastHash, c.KeyID, c.Signature, c.CreatedAt,
+	).Scan(&c.ID)
+}
+
+func (r *PostgresAuditRepo) LatestCheckpoint() (*models.AuditCheckpoint, error) {
+	c := &models.AuditCheckpoint{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT id, last_event_id, last_hash, key_id, signature, created_at
+		 FROM audit_checkpoints ORDER BY id DESC LIMIT 1`,
+	).Scan(&c.ID, &c.LastEventID, &c.LastHash, &c.KeyID, &c.Signature, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *PostgresAuditRepo) ListCheckpoints() ([]models.AuditCheckpoint, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT id, last_event_id, last_hash, key_id, signature, created_at
+		 FROM audit_checkpoints ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []models.AuditCheckpoint{}
+	for rows.Next() {
+		var c models.AuditCheckpoint
+		if err := rows.Scan(&c.ID, &c.LastEventID, &c.LastHash, &c.KeyID, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+
+	return checkpoints, rows.Err()
+}
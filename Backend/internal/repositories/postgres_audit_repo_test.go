@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAuditString(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  string
+	}{
+		{"plain", "Mozilla/5.0", 512, "Mozilla/5.0"},
+		{"invalid utf-8", "bad\xff\xfebytes", 512, "bad�bytes"},
+		{"nul byte", "a\x00b", 512, "a�b"},
+		{"cut to limit", strings.Repeat("a", 600), 512, strings.Repeat("a", 512)},
+		{"cut on a rune boundary", "ab€", 4, "ab"},
+		{"cut after cleaning", "\xff\xff", 3, "�"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := auditString(tt.in, tt.limit)
+			if got != tt.want {
+				t.Errorf("auditString(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > tt.limit {
+				t.Errorf("result %q is not valid UTF-8 within %d bytes", got, tt.limit)
+			}
+		})
+	}
+}
+
+func TestAuditDetails(t *testing.T) {
+	in := map[string]any{
+		"email":  "x\x00@example.com",
+		"amr":    []string{"pwd\xff"},
+		"nested": map[string]any{"ua": strings.Repeat("é", 200)},
+		"count":  3,
+	}
+
+	got := auditDetails(in)
+
+	if got["email"] != "x�@example.com" {
+		t.Errorf("email = %q", got["email"])
+	}
+	if amr := got["amr"].([]string); amr[0] != "pwd�" {
+		t.Errorf("amr = %q", amr)
+	}
+	if ua := got["nested"].(map[string]any)["ua"].(string); len(ua) > auditMaxDetail || !utf8.ValidString(ua) {
+		t.Errorf("nested value not capped: %d bytes", len(ua))
+	}
+	if got["count"] != 3 {
+		t.Errorf("count = %v", got["count"])
+	}
+	if in["email"] != "x\x00@example.com" {
+		t.Error("caller's map was modified")
+	}
+
+	// jsonb rejects \u0000, which encoding/json would emit for a NUL
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `\u0000`) {
+		t.Errorf("details still encode a NUL: %s", b)
+	}
+	if auditDetails(nil) != nil {
+		t.Error("nil details became non-nil")
+	}
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresCommentRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresCommentRepo(db *pgxpool.Pool) *PostgresCommentRepo {
+	return &PostgresCommentRepo{db: db}
+}
+
+func scanComment(row pgx.Row) (*models.TicketComment, error) {
+	c := &models.TicketComment{}
+	err := row.Scan(
+		&c.ID,
+		&c.TicketID,
+		&c.ParentID,
+		&c.AuthorID,
+		&c.Body,
+		&c.Internal,
+		&c.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *PostgresCommentRepo) Create(comment *models.TicketComment) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO ticket_comments (id, ticket_id, parent_id, author_id, body, internal)
+		 VALUES ($1,$2,$3,$4,$5,$6)
+		 RETURNING created_at`,
+		comment.ID,
+		comment.TicketID,
+		comment.ParentID,
+		comment.AuthorID,
+		comment.Body,
+		comment.Internal,
+	).Scan(&comment.CreatedAt)
+}
+
+func (r *PostgresCommentRepo) GetByID(id uuid.UUID) (*models.TicketComment, error) {
+	c, err := scanComment(r.db.QueryRow(
+		context.Background(),
+		`SELECT id, ticket_id, parent_id, author_id, body, internal, created_at
+		 FROM ticket_comments WHERE id=$1`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+func (r *PostgresCommentRepo) ListByTicket(
+	ticketID uuid.UUID,
+	includeInternal bool,
+) ([]models.TicketComment, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT id, ticket_id, parent_id, author_id, body, internal, created_at
+		 FROM ticket_comments
+		 WHERE ticket_id=$1 AND (internal=false OR $2)
+		 ORDER BY created_at`,
+		ticketID, includeInternal,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.TicketComment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *c)
+	}
+
+	return comments, rows.Err()
+}
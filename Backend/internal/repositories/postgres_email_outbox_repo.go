@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresEmailOutboxRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresEmailOutboxRepo(db *pgxpool.Pool) *PostgresEmailOutboxRepo {
+	return &PostgresEmailOutboxRepo{db: db}
+}
+
+func (r *PostgresEmailOutboxRepo) Enqueue(email *models.OutboxEmail) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO email_outbox (id, to_addr, reply_to, subject, text_body, html_body)
+		 VALUES ($1,$2,$3,$4,$5,$6)`,
+		email.ID,
+		email.To,
+		email.ReplyTo,
+		email.Subject,
+		email.Text,
+		email.HTML,
+	)
+	return err
+}
+
+func (r *PostgresEmailOutboxRepo) ClaimDue(limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`UPDATE email_outbox SET next_attempt_at = NOW() + make_interval(secs => $2)
+		 WHERE id IN (
+		   SELECT id FROM email_outbox
+		   WHERE status='pending' AND next_attempt_at <= NOW()
+		   ORDER BY next_attempt_at
+		   LIMIT $1
+		   FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING id, to_addr, reply_to, subject, text_body, html_body, attempts`,
+		limit, lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.OutboxEmail
+	for rows.Next() {
+		var e models.OutboxEmail
+		if err := rows.Scan(&e.ID, &e.To, &e.ReplyTo, &e.Subject, &e.Text, &e.HTML, &e.Attempts); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
+
+func (r *PostgresEmailOutboxRepo) MarkSent(id uuid.UUID) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE email_outbox
+		 SET status='sent', sent_at=NOW(), attempts=attempts+1, last_error='',
+		     text_body='', html_body=''
+		 WHERE id=$1`,
+		id,
+	)
+	return err
+}
+
+func (r *PostgresEmailOutboxRepo) MarkFailed(id uuid.UUID, lastErr string, nextAttempt *time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE email_outbox
+		 SET attempts=attempts+1,
+		     last_error=$2,
+		     status=CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+		     next_attempt_at=COALESCE($3, next_attempt_at),
+		     text_body=CASE WHEN $3::timestamptz IS NULL THEN '' ELSE text_body END,
+		     html_body=CASE WHEN $3::timestamptz IS NULL THEN '' ELSE html_body END
+		 WHERE id=$1`,
+		id, lastErr, nextAttempt,
+	)
+	return err
+}
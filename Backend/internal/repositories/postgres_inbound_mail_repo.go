@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresInboundMailRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresInboundMailRepo(db *pgxpool.Pool) *PostgresInboundMailRepo {
+	return &PostgresInboundMailRepo{db: db}
+}
+
+func (r *PostgresInboundMailRepo) Claim(messageID string) (bool, error) {
+	tag, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO inbound_messages (message_id) VALUES ($1)
+		 ON CONFLICT (message_id) DO NOTHING`,
+		messageID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresInboundMailRepo) Release(messageID string) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM inbound_messages WHERE message_id=$1`,
+		messageID,
+	)
+	return err
+}
+
+func (r *PostgresInboundMailRepo) SetTicket(messageID string, ticketID uuid.UUID) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE inbound_messages SET ticket_id=$2 WHERE message_id=$1`,
+		messageID, ticketID,
+	)
+	return err
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+type PostgresJWTKeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresJWTKeyRepo(db *pgxpool.Pool) *PostgresJWTKeyRepo {
+	return &PostgresJWTKeyRepo{db: db}
+}
+
+func (r *PostgresJWTKeyRepo) ListJWTKeys() ([]models.JWTKey, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT kid, algorithm, private_key, kek_id, created_at, expires_at
+		 FROM jwt_signing_keys
+		 WHERE expires_at > NOW()
+		 ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.JWTKey
+	for rows.Next() {
+		var k models.JWTKey
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &k.KEKID, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *PostgresJWTKeyRepo) CreateJWTKey(k *models.JWTKey) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO jwt_signing_keys (kid, algorithm, private_key, kek_id, expires_at)
+		 VALUES ($1,$2,$3,$4,$5)
+		 RETURNING created_at`,
+		k.ID, k.Algorithm, k.PrivateKey, k.KEKID, k.ExpiresAt,
+	).Scan(&k.CreatedAt)
+}
+
+func (r *PostgresJWTKeyRepo) SealJWTKey(kid string, privateKey []byte, kekID string) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE jwt_signing_keys SET private_key=$2, kek_id=$3
+		 WHERE kid=$1 AND kek_id=''`,
+		kid, privateKey, kekID,
+	)
+	return err
+}
+
+func (r *PostgresJWTKeyRepo) PurgeJWTKeys() error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM jwt_signing_keys WHERE expires_at < NOW()`,
+	)
+	return err
+}
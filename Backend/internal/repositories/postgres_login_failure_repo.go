@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+type PostgresLoginFailureRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresLoginFailureRepo(db *pgxpool.Pool) *PostgresLoginFailureRepo {
+	return &PostgresLoginFailureRepo{db: db}
+}
+
+func (r *PostgresLoginFailureRepo) GetLoginFailure(scope, key string) (*models.LoginFailure, error) {
+	f := &models.LoginFailure{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT scope, key, failures, last_failed_at, locked_until
+		 FROM login_failures WHERE scope=$1 AND key=$2`,
+		scope, key,
+	).Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailedAt, &f.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (r *PostgresLoginFailureRepo) RecordLoginFailure(scope, key string, now, since time.Time) (*models.LoginFailure, error) {
+	f := &models.LoginFailure{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO login_failures (scope, key, failures, last_failed_at)
+		 VALUES ($1,$2,1,$3)
+		 ON CONFLICT (scope, key) DO UPDATE SET
+		     failures = CASE
+		         WHEN login_failures.last_failed_at < $4
+		           OR login_failures.locked_until <= $3 THEN 1
+		         ELSE login_failures.failures + 1
+		     END,
+		     locked_until = CASE
+		         WHEN login_failures.locked_until <= $3 THEN NULL
+		         ELSE login_failures.locked_until
+		     END,
+		     last_failed_at = $3
+		 RETURNING scope, key, failures, last_failed_at, locked_until`,
+		scope, key, now, since,
+	).Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailedAt, &f.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (r *PostgresLoginFailureRepo) LockLogin(scope, key string, until time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE login_failures SET locked_until=$3 WHERE scope=$1 AND key=$2`,
+		scope, key, until,
+	)
+	return err
+}
+
+func (r *PostgresLoginFailureRepo) ClearLoginFailures(scope, key string) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM login_failures WHERE scope=$1 AND key=$2`,
+		scope, key,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresLoginFailureRepo) ListLockedLogins(now time.Time) ([]models.LoginFailure, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT scope, key, failures, last_failed_at, locked_until
+		 FROM login_failures
+		 WHERE locked_until > $1
+		 ORDER BY locked_until DESC`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := []models.LoginFailure{}
+	for rows.Next() {
+		var f models.LoginFailure
+		if err := rows.Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailedAt, &f.LockedUntil); err != nil {
+			return nil, err
+		}
+		locked = append(locked, f)
+	}
+
+	return locked, rows.Err()
+}
+
+func (r *PostgresLoginFailureRepo) PurgeLoginFailures(before time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM login_failures
+		 WHERE last_failed_at < $1
+		   AND (locked_until IS NULL OR locked_until < $1)`,
+		before,
+	)
+	return err
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+type PostgresMFAChallengeRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMFAChallengeRepo(db *pgxpool.Pool) *PostgresMFAChallengeRepo {
+	return &PostgresMFAChallengeRepo{db: db}
+}
+
+func (r *PostgresMFAChallengeRepo) Create(userID uuid.UUID, hash, firstFactor string, expiresAt time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO mfa_challenges (user_id, token_hash, first_factor, expires_at)
+		 VALUES ($1,$2,$3,$4)`,
+		userID, hash, firstFactor, expiresAt,
+	)
+	return err
+}
+
+// ClaimAttempt increments the attempt counter before the code is checked, so
+// concurrent guesses can never exceed maxAttempts.
+func (r *PostgresMFAChallengeRepo) ClaimAttempt(hash string, maxAttempts int) (*models.MFAChallenge, error) {
+	c := &models.MFAChallenge{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`UPDATE mfa_challenges SET attempts = attempts + 1
+		 WHERE token_hash=$1
+		   AND consumed_at IS NULL
+		   AND expires_at > NOW()
+		   AND attempts < $2
+		 RETURNING id, user_id, first_factor, attempts, expires_at, consumed_at`,
+		hash, maxAttempts,
+	).Scan(&c.ID, &c.UserID, &c.FirstFactor, &c.Attempts, &c.ExpiresAt, &c.ConsumedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *PostgresMFAChallengeRepo) Consume(id uuid.UUID) (string, error) {
+	var firstFactor string
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`UPDATE mfa_challenges SET consumed_at=NOW()
+		 WHERE id=$1 AND consumed_at IS NULL
+		 RETURNING first_factor`,
+		id,
+	).Scan(&firstFactor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return firstFactor, err
+}
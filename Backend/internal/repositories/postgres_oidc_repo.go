@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+type PostgresOIDCRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresOIDCRepo(db *pgxpool.Pool) *PostgresOIDCRepo {
+	return &PostgresOIDCRepo{db: db}
+}
+
+func (r *PostgresOIDCRepo) CreateLoginState(stateHash string, s *models.OIDCLoginState) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at)
+		 VALUES ($1,$2,$3,$4)`,
+		stateHash, s.Nonce, s.CodeVerifier, s.ExpiresAt,
+	)
+	return err
+}
+
+func (r *PostgresOIDCRepo) TakeLoginState(stateHash string) (*models.OIDCLoginState, error) {
+	s := &models.OIDCLoginState{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`DELETE FROM oidc_login_states
+		 WHERE state_hash=$1 AND expires_at > NOW()
+		 RETURNING nonce, code_verifier, expires_at`,
+		stateHash,
+	).Scan(&s.Nonce, &s.CodeVerifier, &s.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *PostgresOIDCRepo) IdentityUser(issuer, subject string) (uuid.UUID, error) {
+	var userID uuid.UUID
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT user_id FROM user_identities WHERE issuer=$1 AND subject=$2`,
+		issuer, subject,
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNotFound
+	}
+
+	return userID, err
+}
+
+func (r *PostgresOIDCRepo) LinkIdentity(userID uuid.UUID, issuer, subject string) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO user_identities (user_id, issuer, subject)
+		 VALUES ($1,$2,$3)
+		 ON CONFLICT DO NOTHING`,
+		userID, issuer, subject,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
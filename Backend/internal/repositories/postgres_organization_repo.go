@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresOrganizationRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresOrganizationRepo(db *pgxpool.Pool) *PostgresOrganizationRepo {
+	return &PostgresOrganizationRepo{db: db}
+}
+
+func (r *PostgresOrganizationRepo) Create(org *models.Organization) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO organizations (id, name) VALUES ($1,$2)
+		 RETURNING created_at`,
+		org.ID, org.Name,
+	).Scan(&org.CreatedAt)
+}
+
+func (r *PostgresOrganizationRepo) GetByID(id uuid.UUID) (*models.Organization, error) {
+	o := &models.Organization{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT id, name, created_at FROM organizations WHERE id=$1`,
+		id,
+	).Scan(&o.ID, &o.Name, &o.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (r *PostgresOrganizationRepo) List() ([]models.Organization, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT id, name, created_at FROM organizations ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var o models.Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+
+	return orgs, rows.Err()
+}
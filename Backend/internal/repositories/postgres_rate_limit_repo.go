@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresRateLimitRepo is a middlewares.RateLimitStore shared by every
+// replica. Its tables are UNLOGGED: losing counters in a crash is fine.
+type PostgresRateLimitRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRateLimitRepo(db *pgxpool.Pool) *PostgresRateLimitRepo {
+	return &PostgresRateLimitRepo{db: db}
+}
+
+func (r *PostgresRateLimitRepo) Take(key string, rate float64, burst int, now time.Time) (float64, bool, error) {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1,$2,$3)
+		 ON CONFLICT (key) DO NOTHING`,
+		key, float64(burst), now,
+	); err != nil {
+		return 0, false, err
+	}
+
+	var tokens float64
+	var updated time.Time
+	if err := tx.QueryRow(
+		ctx,
+		`SELECT tokens, updated_at FROM rate_limit_buckets WHERE key=$1 FOR UPDATE`,
+		key,
+	).Scan(&tokens, &updated); err != nil {
+		return 0, false, err
+	}
+
+	tokens = math.Min(float64(burst), tokens+math.Max(now.Sub(updated).Seconds(), 0)*rate)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE rate_limit_buckets SET tokens=$2, updated_at=$3 WHERE key=$1`,
+		key, tokens, now,
+	); err != nil {
+		return 0, false, err
+	}
+
+	return tokens, allowed, tx.Commit(ctx)
+}
+
+func (r *PostgresRateLimitRepo) Give(key string, burst int) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE rate_limit_buckets SET tokens = LEAST(tokens + 1, $2) WHERE key=$1`,
+		key, float64(burst),
+	)
+	return err
+}
+
+func (r *PostgresRateLimitRepo) Incr(key string, window time.Duration, now time.Time) (int, int, error) {
+	ctx := context.Background()
+	start := now.Truncate(window)
+
+	var curr, prev int
+
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO rate_limit_windows (key, window_start, count) VALUES ($1,$2,1)
+		 ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limit_windows.count + 1
+		 RETURNING count`,
+		key, start,
+	).Scan(&curr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = r.db.QueryRow(
+		ctx,
+		`SELECT COALESCE(
+		   (SELECT count FROM rate_limit_windows WHERE key=$1 AND window_start=$2), 0)`,
+		key, start.Add(-window),
+	).Scan(&prev)
+
+	return curr, prev, err
+}
+
+func (r *PostgresRateLimitRepo) Decr(key string, window time.Duration, now time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE rate_limit_windows SET count = count - 1
+		 WHERE key=$1 AND window_start=$2 AND count > 0`,
+		key, now.Truncate(window),
+	)
+	return err
+}
+
+func (r *PostgresRateLimitRepo) Purge(before time.Time) error {
+	ctx := context.Background()
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(ctx, `DELETE FROM rate_limit_windows WHERE window_start < $1`, before)
+	return err
+}
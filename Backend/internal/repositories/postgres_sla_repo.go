@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSLAPolicyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSLAPolicyRepo(db *pgxpool.Pool) *PostgresSLAPolicyRepo {
+	return &PostgresSLAPolicyRepo{db: db}
+}
+
+func (r *PostgresSLAPolicyRepo) List() ([]models.SLAPolicy, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT id, organization_id, priority, first_response_minutes, resolution_minutes
+		 FROM sla_policies
+		 ORDER BY organization_id NULLS FIRST, priority`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.SLAPolicy{}
+	for rows.Next() {
+		var p models.SLAPolicy
+		if err := rows.Scan(
+			&p.ID,
+			&p.OrganizationID,
+			&p.Priority,
+			&p.FirstResponseMinutes,
+			&p.ResolutionMinutes,
+		); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *PostgresSLAPolicyRepo) Upsert(policy *models.SLAPolicy) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO sla_policies
+		   (id, organization_id, priority, first_response_minutes, resolution_minutes)
+		 VALUES ($1,$2,$3,$4,$5)
+		 ON CONFLICT (COALESCE(organization_id, '00000000-0000-0000-0000-000000000000'), priority)
+		 DO UPDATE SET first_response_minutes=EXCLUDED.first_response_minutes,
+		               resolution_minutes=EXCLUDED.resolution_minutes
+		 RETURNING id`,
+		policy.ID,
+		policy.OrganizationID,
+		policy.Priority,
+		policy.FirstResponseMinutes,
+		policy.ResolutionMinutes,
+	).Scan(&policy.ID)
+}
+
+func (r *PostgresSLAPolicyRepo) Delete(id uuid.UUID) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM sla_policies WHERE id=$1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresSLAPolicyRepo) Resolve(
+	organizationID *uuid.UUID,
+	priority string,
+) (*models.SLAPolicy, error) {
+	p := &models.SLAPolicy{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT id, organization_id, priority, first_response_minutes, resolution_minutes
+		 FROM sla_policies
+		 WHERE priority=$1 AND (organization_id=$2 OR organization_id IS NULL)
+		 ORDER BY organization_id NULLS LAST
+		 LIMIT 1`,
+		priority, organizationID,
+	).Scan(
+		&p.ID,
+		&p.OrganizationID,
+		&p.Priority,
+		&p.FirstResponseMinutes,
+		&p.ResolutionMinutes,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
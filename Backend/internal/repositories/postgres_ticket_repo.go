@@ -0,0 +1,419 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ticketapp/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const ticketColumns = `id, subject, description, status, priority,
+	requester_id, assignee_id, organization_id, created_at, updated_at,
+	first_response_due, resolution_due, first_responded_at, resolved_at,
+	sla_paused_at, sla_paused_seconds, first_response_breached, resolution_breached`
+
+type PostgresTicketRepo struct {
+	db    *pgxpool.Pool
+	scope TicketScope
+}
+
+func NewPostgresTicketRepo(db *pgxpool.Pool) *PostgresTicketRepo {
+	return &PostgresTicketRepo{db: db}
+}
+
+// Scoped returns a repository whose every query is limited to scope.
+func (r *PostgresTicketRepo) Scoped(scope TicketScope) TicketRepository {
+	return &PostgresTicketRepo{db: r.db, scope: scope}
+}
+
+// scopeSQL returns the tenant predicate for this repository as an
+// "AND ..." fragment, numbering its placeholder after n existing args.
+// alias qualifies the columns when the query joins other tables.
+func (r *PostgresTicketRepo) scopeSQL(alias string, n int) (string, []any) {
+	if alias != "" {
+		alias += "."
+	}
+
+	switch {
+	case r.scope.OrganizationID != nil:
+		return fmt.Sprintf(" AND %sorganization_id=$%d", alias, n+1), []any{*r.scope.OrganizationID}
+	case r.scope.RequesterID != nil:
+		return fmt.Sprintf(" AND %srequester_id=$%d", alias, n+1), []any{*r.scope.RequesterID}
+	}
+	return "", nil
+}
+
+// allows reports whether a ticket falls inside this repository's scope.
+func (r *PostgresTicketRepo) allows(t *models.Ticket) bool {
+	switch {
+	case r.scope.OrganizationID != nil:
+		return t.OrganizationID != nil && *t.OrganizationID == *r.scope.OrganizationID
+	case r.scope.RequesterID != nil:
+		return t.RequesterID == *r.scope.RequesterID
+	}
+	return true
+}
+
+func scanTicket(row pgx.Row) (*models.Ticket, error) {
+	t := &models.Ticket{}
+	err := row.Scan(
+		&t.ID,
+		&t.Subject,
+		&t.Description,
+		&t.Status,
+		&t.Priority,
+		&t.RequesterID,
+		&t.AssigneeID,
+		&t.OrganizationID,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+		&t.FirstResponseDue,
+		&t.ResolutionDue,
+		&t.FirstRespondedAt,
+		&t.ResolvedAt,
+		&t.SLAPausedAt,
+		&t.SLAPausedSeconds,
+		&t.FirstResponseBreached,
+		&t.ResolutionBreached,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *PostgresTicketRepo) Create(ticket *models.Ticket) error {
+	if !r.allows(ticket) {
+		return ErrNotFound
+	}
+
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO tickets
+		   (id, subject, description, status, priority, requester_id, assignee_id,
+		    organization_id, created_at, first_response_due, resolution_due)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		 RETURNING created_at, updated_at`,
+		ticket.ID,
+		ticket.Subject,
+		ticket.Description,
+		ticket.Status,
+		ticket.Priority,
+		ticket.RequesterID,
+		ticket.AssigneeID,
+		ticket.OrganizationID,
+		ticket.CreatedAt,
+		ticket.FirstResponseDue,
+		ticket.ResolutionDue,
+	).Scan(&ticket.CreatedAt, &ticket.UpdatedAt)
+}
+
+func (r *PostgresTicketRepo) GetByID(id uuid.UUID) (*models.Ticket, error) {
+	scope, scopeArgs := r.scopeSQL("", 1)
+
+	t, err := scanTicket(r.db.QueryRow(
+		context.Background(),
+		`SELECT `+ticketColumns+` FROM tickets WHERE id=$1`+scope,
+		append([]any{id}, scopeArgs...)...,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *PostgresTicketRepo) List(filter TicketFilter) ([]models.Ticket, error) {
+	var (
+		where []string
+		args  []any
+	)
+
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.RequesterID != nil {
+		add("requester_id=$%d", *filter.RequesterID)
+	}
+	if filter.AssigneeID != nil {
+		add("assignee_id=$%d", *filter.AssigneeID)
+	}
+	if filter.Status != "" {
+		add("status=$%d", filter.Status)
+	}
+	if filter.Priority != "" {
+		add("priority=$%d", filter.Priority)
+	}
+	if filter.Query != "" {
+		add("search_vector @@ websearch_to_tsquery('english', $%d)", filter.Query)
+	}
+	if filter.CreatedFrom != nil {
+		add("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("created_at < $%d", *filter.CreatedTo)
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	scope, scopeArgs := r.scopeSQL("", len(args))
+	args = append(args, scopeArgs...)
+
+	query := `SELECT ` + ticketColumns + ` FROM tickets WHERE true` + scope
+	if len(where) > 0 {
+		query += ` AND ` + strings.Join(where, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxTicketListLimit {
+		limit = MaxTicketListLimit
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []models.Ticket{}
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *t)
+	}
+
+	return tickets, rows.Err()
+}
+
+func (r *PostgresTicketRepo) Update(ticket *models.Ticket) error {
+	scope, scopeArgs := r.scopeSQL("", 8)
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`UPDATE tickets
+		 SET subject=$1, description=$2, status=$3, priority=$4, assignee_id=$5,
+		     first_response_due=$6, resolution_due=$7, updated_at=NOW()
+		 WHERE id=$8`+scope+`
+		 RETURNING updated_at`,
+		append([]any{
+			ticket.Subject,
+			ticket.Description,
+			ticket.Status,
+			ticket.Priority,
+			ticket.AssigneeID,
+			ticket.FirstResponseDue,
+			ticket.ResolutionDue,
+			ticket.ID,
+		}, scopeArgs...)...,
+	).Scan(&ticket.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *PostgresTicketRepo) Delete(id uuid.UUID) error {
+	scope, scopeArgs := r.scopeSQL("", 1)
+
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM tickets WHERE id=$1`+scope,
+		append([]any{id}, scopeArgs...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Transition moves the ticket to change.ToStatus only if it is still in
+// change.FromStatus, and records the history row in the same transaction.
+//
+// The SLA clock is paused while a ticket waits on the customer: entering
+// that status stamps sla_paused_at, leaving it pushes the open deadlines
+// back by the time spent paused.
+func (r *PostgresTicketRepo) Transition(change *models.TicketStatusChange) (*models.Ticket, error) {
+	ctx := context.Background()
+	scope, scopeArgs := r.scopeSQL("t", 3)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ticket, err := scanTicket(tx.QueryRow(
+		ctx,
+		`WITH paused AS (
+		   SELECT id, COALESCE(EXTRACT(EPOCH FROM NOW() - sla_paused_at)::BIGINT, 0) AS secs
+		   FROM tickets WHERE id=$2
+		 )
+		 UPDATE tickets t SET
+		   status=$1,
+		   updated_at=NOW(),
+		   sla_paused_at = CASE
+		     WHEN $1 = '`+models.TicketStatusWaitingOnCustomer+`' THEN COALESCE(t.sla_paused_at, NOW())
+		     ELSE NULL END,
+		   sla_paused_seconds = t.sla_paused_seconds + CASE
+		     WHEN $1 = '`+models.TicketStatusWaitingOnCustomer+`' THEN 0 ELSE p.secs END,
+		   first_response_due = CASE
+		     WHEN $1 <> '`+models.TicketStatusWaitingOnCustomer+`' AND t.first_responded_at IS NULL
+		     THEN t.first_response_due + make_interval(secs => p.secs)
+		     ELSE t.first_response_due END,
+		   resolution_due = CASE
+		     WHEN $1 <> '`+models.TicketStatusWaitingOnCustomer+`'
+		     THEN t.resolution_due + make_interval(secs => p.secs)
+		     ELSE t.resolution_due END,
+		   resolved_at = CASE
+		     WHEN $1 IN ('`+models.TicketStatusResolved+`', '`+models.TicketStatusClosed+`')
+		     THEN COALESCE(t.resolved_at, NOW())
+		     ELSE NULL END
+		 FROM paused p
+		 WHERE t.id=p.id AND t.status=$3`+scope+`
+		 RETURNING `+prefixColumns("t", ticketColumns),
+		append([]any{change.ToStatus, change.TicketID, change.FromStatus}, scopeArgs...)...,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO ticket_status_history (id, ticket_id, from_status, to_status, changed_by, note)
+		 VALUES ($1,$2,$3,$4,$5,$6)
+		 RETURNING created_at`,
+		change.ID,
+		change.TicketID,
+		change.FromStatus,
+		change.ToStatus,
+		change.ChangedBy,
+		change.Note,
+	).Scan(&change.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return ticket, tx.Commit(ctx)
+}
+
+func (r *PostgresTicketRepo) ListStatusHistory(ticketID uuid.UUID) ([]models.TicketStatusChange, error) {
+	scope, scopeArgs := r.scopeSQL("t", 1)
+
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT h.id, h.ticket_id, h.from_status, h.to_status, h.changed_by, h.note, h.created_at
+		 FROM ticket_status_history h
+		 JOIN tickets t ON t.id = h.ticket_id
+		 WHERE h.ticket_id=$1`+scope+`
+		 ORDER BY h.created_at`,
+		append([]any{ticketID}, scopeArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.TicketStatusChange{}
+	for rows.Next() {
+		var c models.TicketStatusChange
+		if err := rows.Scan(
+			&c.ID,
+			&c.TicketID,
+			&c.FromStatus,
+			&c.ToStatus,
+			&c.ChangedBy,
+			&c.Note,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+
+	return history, rows.Err()
+}
+
+// MarkFirstResponse stamps the first staff reply; later calls are no-ops.
+func (r *PostgresTicketRepo) MarkFirstResponse(ticketID uuid.UUID) error {
+	scope, scopeArgs := r.scopeSQL("", 1)
+
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE tickets SET first_responded_at=NOW()
+		 WHERE id=$1 AND first_responded_at IS NULL`+scope,
+		append([]any{ticketID}, scopeArgs...)...,
+	)
+	return err
+}
+
+// FlagSLABreaches marks running tickets whose deadlines passed before now
+// and returns the ids newly flagged. It is a background job and ignores the
+// repository scope.
+func (r *PostgresTicketRepo) FlagSLABreaches(now time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`WITH fr AS (
+		   UPDATE tickets SET first_response_breached=true
+		   WHERE first_response_breached=false
+		     AND first_responded_at IS NULL
+		     AND sla_paused_at IS NULL
+		     AND resolved_at IS NULL
+		     AND first_response_due < $1
+		   RETURNING id
+		 ), res AS (
+		   UPDATE tickets SET resolution_breached=true
+		   WHERE resolution_breached=false
+		     AND resolved_at IS NULL
+		     AND sla_paused_at IS NULL
+		     AND resolution_due < $1
+		   RETURNING id
+		 )
+		 SELECT id FROM fr UNION SELECT id FROM res`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias.
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, p := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -3,7 +3,6 @@ package repositories
 import (
 	"context"
 	"errors"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -20,30 +19,68 @@ func NewPostgresRefreshTokenRepo(db *pgxpool.Pool) *PostgresRefreshTokenRepo {
 	return &PostgresRefreshTokenRepo{db: db}
 }
 
-func (r *PostgresRefreshTokenRepo) Store(
-	userID uuid.UUID,
-	hash string,
-	exp time.Time,
-) error {
-	_, err := r.db.Exec(
+// Store saves the first token of a new session.
+func (r *PostgresRefreshTokenRepo) Store(t *models.RefreshToken) error {
+	return r.db.QueryRow(
 		context.Background(),
-		`INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
-		 VALUES ($1,$2,$3)`,
-		userID, hash, exp,
-	)
-	return err
+		`INSERT INTO refresh_tokens (user_id, token_hash, expires_at, user_agent, ip_address, amr)
+		 VALUES ($1,$2,$3,$4,$5,$6)
+		 RETURNING id, session_id, session_started_at`,
+		t.UserID, t.Hash, t.ExpiresAt, t.UserAgent, t.IPAddress, t.AMR,
+	).Scan(&t.ID, &t.SessionID, &t.SessionStartedAt)
 }
 
+// Rotate revokes oldID and stores next in the same session. Only one
+// caller can rotate a given token; the others get ErrNotFound.
+func (r *PostgresRefreshTokenRepo) Rotate(oldID uuid.UUID, next *models.RefreshToken) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`UPDATE refresh_tokens SET revoked=true, revoked_at=NOW()
+		 WHERE id=$1 AND revoked=false
+		 RETURNING session_id, session_started_at, amr`,
+		oldID,
+	).Scan(&next.SessionID, &next.SessionStartedAt, &next.AMR)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO refresh_tokens
+		   (user_id, token_hash, expires_at, user_agent, ip_address, session_id, session_started_at, amr)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		 RETURNING id`,
+		next.UserID, next.Hash, next.ExpiresAt, next.UserAgent, next.IPAddress,
+		next.SessionID, next.SessionStartedAt, next.AMR,
+	).Scan(&next.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
 
 func (r *PostgresRefreshTokenRepo) GetValid(hash string) (*models.RefreshToken, error) {
 	t := &models.RefreshToken{}
 
 	err := r.db.QueryRow(
 		context.Background(),
-		`SELECT id, user_id FROM refresh_tokens
+		`SELECT id, user_id, session_id, expires_at, user_agent, ip_address, session_started_at
+		 FROM refresh_tokens
 		 WHERE token_hash=$1 AND revoked=false AND expires_at > NOW()`,
 		hash,
-	).Scan(&t.ID, &t.UserID)
+	).Scan(&t.ID, &t.UserID, &t.SessionID, &t.ExpiresAt, &t.UserAgent, &t.IPAddress, &t.SessionStartedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -52,14 +89,40 @@ func (r *PostgresRefreshTokenRepo) GetValid(hash string) (*models.RefreshToken,
 		return nil, err
 	}
 
+	t.Hash = hash
 	return t, nil
 }
 
+// GetByHash finds a token whatever its state, so the caller can tell a
+// replayed (revoked) token from one that never existed.
+func (r *PostgresRefreshTokenRepo) GetByHash(hash string) (*models.RefreshToken, error) {
+	t := &models.RefreshToken{Hash: hash}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT id, user_id, session_id, expires_at, user_agent, ip_address,
+		        session_started_at, revoked, revoked_at
+		 FROM refresh_tokens
+		 WHERE token_hash=$1`,
+		hash,
+	).Scan(
+		&t.ID, &t.UserID, &t.SessionID, &t.ExpiresAt, &t.UserAgent, &t.IPAddress,
+		&t.SessionStartedAt, &t.Revoked, &t.RevokedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
 
 func (r *PostgresRefreshTokenRepo) Revoke(tokenID uuid.UUID) error {
 	cmd, err := r.db.Exec(
 		context.Background(),
-		`UPDATE refresh_tokens SET revoked=true WHERE id=$1`,
+		`UPDATE refresh_tokens SET revoked=true, revoked_at=COALESCE(revoked_at, NOW()) WHERE id=$1`,
 		tokenID,
 	)
 	if err != nil {
@@ -76,8 +139,65 @@ func (r *PostgresRefreshTokenRepo) Revoke(tokenID uuid.UUID) error {
 func (r *PostgresRefreshTokenRepo) RevokeAll(userID uuid.UUID) error {
 	_, err := r.db.Exec(
 		context.Background(),
-		`UPDATE refresh_tokens SET revoked=true WHERE user_id=$1`,
+		`UPDATE refresh_tokens SET revoked=true, revoked_at=NOW()
+		 WHERE user_id=$1 AND revoked=false`,
 		userID,
 	)
 	return err
 }
+
+// ListSessions returns the user's live sessions, most recently used first.
+func (r *PostgresRefreshTokenRepo) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT session_id, user_agent, ip_address, session_started_at, last_used_at, expires_at
+		 FROM refresh_tokens
+		 WHERE user_id=$1 AND revoked=false AND expires_at > NOW()
+		 ORDER BY last_used_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(
+			&s.ID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *PostgresRefreshTokenRepo) RevokeSession(userID, sessionID uuid.UUID) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE refresh_tokens SET revoked=true, revoked_at=NOW()
+		 WHERE user_id=$1 AND session_id=$2 AND revoked=false`,
+		userID, sessionID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRefreshTokenRepo) RevokeOtherSessions(userID, keepSessionID uuid.UUID) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE refresh_tokens SET revoked=true, revoked_at=NOW()
+		 WHERE user_id=$1 AND session_id<>$2 AND revoked=false`,
+		userID, keepSessionID,
+	)
+	return err
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// StorePendingOTPSecret starts (or restarts) enrollment with a new secret.
+// It refuses to replace a confirmed secret; 2FA must be disabled first.
+func (r *PostgresUserRepo) StorePendingOTPSecret(userID uuid.UUID, secret string) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`INSERT INTO otp_secrets (user_id, secret, confirmed_at, created_at)
+		 VALUES ($1,$2,NULL,NOW())
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET secret=EXCLUDED.secret, created_at=NOW()
+		 WHERE otp_secrets.confirmed_at IS NULL`,
+		userID, secret,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// GetPendingOTPSecret returns the unconfirmed secret if enrollment started
+// within the last 15 minutes.
+func (r *PostgresUserRepo) GetPendingOTPSecret(userID uuid.UUID) (string, error) {
+	var secret string
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`SELECT secret FROM otp_secrets
+		 WHERE user_id=$1 AND confirmed_at IS NULL
+		   AND created_at > NOW() - INTERVAL '15 minutes'`,
+		userID,
+	).Scan(&secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	return secret, err
+}
+
+func (r *PostgresUserRepo) ConfirmOTPSecret(userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(
+		ctx,
+		`UPDATE otp_secrets SET confirmed_at=NOW(), last_used_step=$2
+		 WHERE user_id=$1 AND confirmed_at IS NULL`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE users SET is_2fa_enabled=true WHERE id=$1`,
+		userID,
+	); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresUserRepo) AcceptOTPStep(userID uuid.UUID, step int64) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE otp_secrets SET last_used_step=$2
+		 WHERE user_id=$1 AND confirmed_at IS NOT NULL
+		   AND (last_used_step IS NULL OR last_used_step < $2)`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *PostgresUserRepo) Disable2FA(userID uuid.UUID) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, q := range []string{
+		`DELETE FROM otp_secrets WHERE user_id=$1`,
+		`DELETE FROM recovery_codes WHERE user_id=$1`,
+		`UPDATE users SET is_2fa_enabled=false WHERE id=$1`,
+	} {
+		if _, err := tx.Exec(ctx, q, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresUserRepo) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, hashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.Exec(
+			ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1,$2)`,
+			userID, hash,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresUserRepo) UseRecoveryCode(userID uuid.UUID, hash string) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE recovery_codes SET used_at=NOW()
+		 WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL`,
+		userID, hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -46,10 +46,10 @@ func (r *PostgresUserRepo) GetByID(id uuid.UUID) (*models.User, error) {
 
 	err := r.db.QueryRow(
 		context.Background(),
-		`SELECT id, username, password_hash, role, is_active
+		`SELECT id, email, username, password_hash, role, is_active, is_2fa_enabled, is_available, organization_id
 		 FROM users WHERE id=$1`,
 		id,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.IsActive)
+	).Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash, &u.Role, &u.IsActive, &u.Is2FAEnabled, &u.IsAvailable, &u.OrganizationID)
 
 	if err != nil {
 		return nil, err
@@ -64,10 +64,10 @@ func (r *PostgresUserRepo) GetByEmail(email string) (*models.User, error) {
 
 	err := r.db.QueryRow(
 		context.Background(),
-		`SELECT id, email, password_hash, role, is_active
-		 FROM users WHERE email=$1`,
+		`SELECT id, email, password_hash, role, is_active, is_2fa_enabled, is_available, organization_id
+		 FROM users WHERE lower(email)=lower($1)`,
 		email,
-	).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.IsActive)
+	).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.IsActive, &u.Is2FAEnabled, &u.IsAvailable, &u.OrganizationID)
 
 	if err != nil {
 		return nil, err
@@ -81,7 +81,7 @@ func (r *PostgresUserRepo) GetOTPSecret(userID uuid.UUID) (string, error) {
 
 	err := r.db.QueryRow(
 		context.Background(),
-		`SELECT secret FROM otp_secrets WHERE user_id=$1`,
+		`SELECT secret FROM otp_secrets WHERE user_id=$1 AND confirmed_at IS NOT NULL`,
 		userID,
 	).Scan(&secret)
 
@@ -103,13 +103,21 @@ func (r *PostgresUserRepo) StoreResetToken(
 }
 
 
-func (r *PostgresUserRepo) ValidateResetToken(hash string) (uuid.UUID, error) {
+func (r *PostgresUserRepo) ConsumeResetToken(hash string) (uuid.UUID, error) {
 	var userID uuid.UUID
 
+	// a concurrent redemption finds the rows already gone and gets no row
 	err := r.db.QueryRow(
 		context.Background(),
-		`SELECT user_id FROM password_resets
-		 WHERE token_hash=$1 AND expires_at > NOW()`,
+		`WITH redeemed AS (
+		   SELECT user_id FROM password_resets
+		   WHERE token_hash=$1 AND expires_at > NOW()
+		 ), deleted AS (
+		   DELETE FROM password_resets
+		   WHERE user_id IN (SELECT user_id FROM redeemed)
+		   RETURNING user_id
+		 )
+		 SELECT DISTINCT user_id FROM deleted`,
 		hash,
 	).Scan(&userID)
 
@@ -130,18 +138,27 @@ func (r *PostgresUserRepo) UpdatePassword(
 }
 
 func (r *PostgresUserRepo) Create(user models.User) error {
-	_, err := r.db.Exec(
+	cmd, err := r.db.Exec(
 		context.Background(),
-		`INSERT INTO users (id, email, username, password_hash, role, is_active)
-		 VALUES ($1,$2,$3,$4,$5,$6)`,
+		`INSERT INTO users (id, email, username, password_hash, role, is_active, organization_id)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7)
+		 ON CONFLICT DO NOTHING`,
 		user.ID,
 		user.Email,
 		user.Username,
 		user.PasswordHash,
 		user.Role,
 		user.IsActive,
+		user.OrganizationID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
 }
 
 
@@ -161,3 +178,87 @@ func (r *PostgresUserRepo) Disable(userID uuid.UUID) error {
 	return nil
 }
 
+
+func (r *PostgresUserRepo) SetRole(userID uuid.UUID, role string) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE users
+		 SET role=$1,
+		     organization_id = CASE WHEN $1='customer' THEN organization_id END
+		 WHERE id=$2`,
+		role, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresUserRepo) SetAvailability(userID uuid.UUID, available bool) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE users SET is_available=$1 WHERE id=$2 AND role='support'`,
+		available, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PickAgent chooses the next active, available support agent and stamps
+// last_assigned_at so concurrent pickers and other replicas rotate fairly.
+//
+// round_robin takes whoever was assigned least recently; least_loaded takes
+// the agent with the fewest unresolved tickets, breaking ties round-robin.
+func (r *PostgresUserRepo) PickAgent(strategy string) (uuid.UUID, error) {
+	order := `u.last_assigned_at NULLS FIRST`
+	if strategy == models.AssignLeastLoaded {
+		order = `(SELECT COUNT(*) FROM tickets t
+		          WHERE t.assignee_id = u.id
+		            AND t.status NOT IN ('resolved', 'closed')),
+		         u.last_assigned_at NULLS FIRST`
+	}
+
+	var id uuid.UUID
+	err := r.db.QueryRow(
+		context.Background(),
+		`UPDATE users SET last_assigned_at=NOW()
+		 WHERE id = (
+		   SELECT u.id FROM users u
+		   WHERE u.role='support' AND u.is_active AND u.is_available
+		   ORDER BY `+order+`
+		   LIMIT 1
+		   FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING id`,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNotFound
+	}
+	return id, err
+}
+
+func (r *PostgresUserRepo) SetOrganization(userID uuid.UUID, orgID *uuid.UUID) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`UPDATE users SET organization_id=$1 WHERE id=$2`,
+		orgID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
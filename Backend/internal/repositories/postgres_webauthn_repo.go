@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ticketapp/internal/models"
+)
+
+type PostgresWebAuthnRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresWebAuthnRepo(db *pgxpool.Pool) *PostgresWebAuthnRepo {
+	return &PostgresWebAuthnRepo{db: db}
+}
+
+func (r *PostgresWebAuthnRepo) ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	rows, err := r.db.Query(
+		context.Background(),
+		`SELECT id, user_id, credential_id, name, credential, created_at, last_used_at
+		 FROM webauthn_credentials
+		 WHERE user_id=$1
+		 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		var c models.WebAuthnCredential
+		if err := rows.Scan(
+			&c.ID, &c.UserID, &c.CredentialID, &c.Name, &c.Data, &c.CreatedAt, &c.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+
+	return creds, rows.Err()
+}
+
+func (r *PostgresWebAuthnRepo) CreateCredential(c *models.WebAuthnCredential) error {
+	err := r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO webauthn_credentials (user_id, credential_id, name, credential)
+		 VALUES ($1,$2,$3,$4)
+		 ON CONFLICT (credential_id) DO NOTHING
+		 RETURNING id, created_at`,
+		c.UserID, c.CredentialID, c.Name, string(c.Data),
+	).Scan(&c.ID, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (r *PostgresWebAuthnRepo) TouchCredential(credentialID []byte, data []byte) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`UPDATE webauthn_credentials SET credential=$2, last_used_at=NOW()
+		 WHERE credential_id=$1`,
+		credentialID, string(data),
+	)
+	return err
+}
+
+func (r *PostgresWebAuthnRepo) DeleteCredential(userID, id uuid.UUID) error {
+	cmd, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM webauthn_credentials WHERE id=$1 AND user_id=$2`,
+		id, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebAuthnRepo) CreateCeremony(hash string, c *models.WebAuthnCeremony) error {
+	return r.db.QueryRow(
+		context.Background(),
+		`INSERT INTO webauthn_ceremonies
+		   (token_hash, kind, user_id, mfa_challenge_id, session, expires_at)
+		 VALUES ($1,$2,$3,$4,$5,$6)
+		 RETURNING id`,
+		hash, c.Kind, c.UserID, c.MFAChallengeID, string(c.Session), c.ExpiresAt,
+	).Scan(&c.ID)
+}
+
+func (r *PostgresWebAuthnRepo) TakeCeremony(hash, kind string) (*models.WebAuthnCeremony, error) {
+	c := &models.WebAuthnCeremony{}
+
+	err := r.db.QueryRow(
+		context.Background(),
+		`DELETE FROM webauthn_ceremonies
+		 WHERE token_hash=$1 AND kind=$2 AND expires_at > NOW()
+		 RETURNING id, kind, user_id, mfa_challenge_id, session, expires_at`,
+		hash, kind,
+	).Scan(&c.ID, &c.Kind, &c.UserID, &c.MFAChallengeID, &c.Session, &c.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *PostgresWebAuthnRepo) PurgeCeremonies(before time.Time) error {
+	_, err := r.db.Exec(
+		context.Background(),
+		`DELETE FROM webauthn_ceremonies WHERE expires_at < $1`,
+		before,
+	)
+	return err
+}
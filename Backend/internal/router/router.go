@@ -2,9 +2,11 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	"ticketapp/internal/handlers"
 	"ticketapp/internal/middlewares"
+	"ticketapp/internal/models"
 	"ticketapp/internal/services"
 )
 
@@ -12,11 +14,69 @@ import (
 func NewRouter(
 	authHandler *handlers.AuthHandler,
 	adminHandler *handlers.AdminHandler,
+	ticketHandler *handlers.TicketHandler,
+	commentHandler *handlers.CommentHandler,
+	attachmentHandler *handlers.AttachmentHandler,
+	slaHandler *handlers.SLAPolicyHandler,
+	agentHandler *handlers.AgentHandler,
+	orgHandler *handlers.OrganizationHandler,
+	inboundMailHandler *handlers.InboundMailHandler,
+	sessionHandler *handlers.SessionHandler,
+	jwksHandler *handlers.JWKSHandler,
 	jwtService *services.JWTService,
+	denylist services.TokenDenylist,
+	rateStore middlewares.RateLimitStore,
 ) http.Handler {
 
 	mux := http.NewServeMux()
 
+	// authed wraps h with the headers + JWT middlewares shared by API routes
+	authed := func(h http.Handler) http.Handler {
+		return middlewares.SecurityHeaders(
+			middlewares.AuthMiddleware(jwtService, denylist)(h),
+		)
+	}
+
+	// -------------------------
+	// RATE LIMIT POLICIES
+	// -------------------------
+
+	perIP := func(name string, limit int, per time.Duration) middlewares.RateLimitPolicy {
+		return middlewares.RateLimitPolicy{
+			Name:    name + ":ip",
+			Limiter: middlewares.NewTokenBucket(rateStore, limit, per),
+			Key:     middlewares.ByIP,
+		}
+	}
+	perEmail := func(name string, limit int, window time.Duration) middlewares.RateLimitPolicy {
+		return middlewares.RateLimitPolicy{
+			Name:    name + ":email",
+			Limiter: middlewares.NewSlidingWindow(rateStore, limit, window),
+			Key:     middlewares.ByEmail,
+		}
+	}
+	perUser := func(name string, limit int, window time.Duration) middlewares.RateLimitPolicy {
+		return middlewares.RateLimitPolicy{
+			Name:    name + ":user",
+			Limiter: middlewares.NewSlidingWindow(rateStore, limit, window),
+			Key:     middlewares.ByUserID,
+		}
+	}
+
+	loginLimit := middlewares.Limit(
+		perIP("login", 10, time.Minute),
+		perEmail("login", 5, 15*time.Minute),
+	)
+	otpLimit := middlewares.Limit(perIP("otp", 5, time.Minute))
+	resetLimit := middlewares.Limit(
+		perIP("reset", 5, time.Hour),
+		perEmail("reset", 3, time.Hour),
+	)
+	resetConfirmLimit := middlewares.Limit(perIP("reset-confirm", 5, time.Minute))
+	mfaManageLimit := middlewares.Limit(perUser("mfa", 5, 15*time.Minute))
+	webauthnLimit := middlewares.Limit(perIP("webauthn", 20, time.Minute))
+	ssoLimit := middlewares.Limit(perIP("sso", 20, time.Minute))
+
 	// -------------------------
 	// AUTH ROUTES (PUBLIC)
 	// -------------------------
@@ -24,7 +84,7 @@ func NewRouter(
 	mux.Handle(
 		"/auth/login",
 		middlewares.SecurityHeaders(
-			middlewares.RateLimit(
+			loginLimit(
 				http.HandlerFunc(authHandler.Login),
 			),
 		),
@@ -33,7 +93,7 @@ func NewRouter(
 	mux.Handle(
 		"/auth/verify-otp",
 		middlewares.SecurityHeaders(
-			middlewares.RateLimit(
+			otpLimit(
 				http.HandlerFunc(authHandler.VerifyOTP),
 			),
 		),
@@ -46,17 +106,19 @@ func NewRouter(
 		),
 	)
 
-	// mux.Handle(
-	// 	"/auth/logout",
-	// 	middlewares.SecurityHeaders(
-	// 		http.HandlerFunc(authHandler.Logout),
-	// 	),
-	// )
+	mux.Handle(
+		"POST /auth/logout",
+		middlewares.SecurityHeaders(
+			http.HandlerFunc(authHandler.Logout),
+		),
+	)
+
+	mux.Handle("POST /auth/logout-all", authed(http.HandlerFunc(authHandler.LogoutAll)))
 
 	mux.Handle(
 		"/auth/forgot-password",
 		middlewares.SecurityHeaders(
-			middlewares.RateLimit(
+			resetLimit(
 				http.HandlerFunc(authHandler.ForgotPassword),
 			),
 		),
@@ -65,7 +127,7 @@ func NewRouter(
 	mux.Handle(
 		"/auth/reset-password",
 		middlewares.SecurityHeaders(
-			middlewares.RateLimit(
+			resetConfirmLimit(
 				http.HandlerFunc(authHandler.ResetPassword),
 			),
 		),
@@ -75,39 +137,162 @@ func NewRouter(
 	// 2FA SETUP (AUTH REQUIRED)
 	// -------------------------
 
-	// mux.Handle(
-	// 	"/auth/setup-2fa",
-	// 	middlewares.SecurityHeaders(
-	// 		middlewares.AuthMiddleware(jwtService)(
-	// 			http.HandlerFunc(authHandler.Setup2FA),
-	// 		),
-	// 	),
-	// )
+	mux.Handle("POST /auth/setup-2fa", authed(http.HandlerFunc(authHandler.Setup2FA)))
+	mux.Handle(
+		"POST /auth/confirm-2fa",
+		authed(mfaManageLimit(http.HandlerFunc(authHandler.Confirm2FA))),
+	)
+	mux.Handle(
+		"POST /auth/disable-2fa",
+		authed(mfaManageLimit(http.HandlerFunc(authHandler.Disable2FA))),
+	)
+	mux.Handle(
+		"POST /auth/recovery-codes",
+		authed(mfaManageLimit(http.HandlerFunc(authHandler.RegenerateRecoveryCodes))),
+	)
+
+	// -------------------------
+	// STAFF SSO (OIDC)
+	// -------------------------
 
-	// mux.Handle(
-	// 	"/auth/confirm-2fa",
-	// 	middlewares.SecurityHeaders(
-	// 		middlewares.RateLimit(
-	// 			http.HandlerFunc(authHandler.Confirm2FA),
-	// 		),
-	// 	),
-	// )
+	mux.Handle(
+		"GET /auth/oidc/login",
+		middlewares.SecurityHeaders(
+			ssoLimit(http.HandlerFunc(authHandler.SSOLogin)),
+		),
+	)
+	mux.Handle(
+		"GET /auth/oidc/callback",
+		middlewares.SecurityHeaders(
+			ssoLimit(http.HandlerFunc(authHandler.SSOCallback)),
+		),
+	)
 
 	// -------------------------
-	// ADMIN ROUTES (RBAC)
+	// WEBAUTHN / PASSKEYS
 	// -------------------------
 
 	mux.Handle(
-		"/admin/users",
+		"POST /auth/webauthn/register/begin",
+		authed(http.HandlerFunc(authHandler.BeginWebAuthnRegistration)),
+	)
+	mux.Handle(
+		"POST /auth/webauthn/register/finish",
+		authed(http.HandlerFunc(authHandler.FinishWebAuthnRegistration)),
+	)
+	mux.Handle("GET /auth/webauthn/credentials", authed(http.HandlerFunc(authHandler.ListWebAuthnCredentials)))
+	mux.Handle(
+		"DELETE /auth/webauthn/credentials/{id}",
+		authed(http.HandlerFunc(authHandler.DeleteWebAuthnCredential)),
+	)
+
+	mux.Handle(
+		"POST /auth/webauthn/login/begin",
 		middlewares.SecurityHeaders(
-			middlewares.AuthMiddleware(jwtService)(
-				middlewares.RequireRole("admin")(
-					http.HandlerFunc(adminHandler.CreateUser),
-				),
+			webauthnLimit(http.HandlerFunc(authHandler.BeginWebAuthnMFA)),
+		),
+	)
+	mux.Handle(
+		"POST /auth/webauthn/login/finish",
+		middlewares.SecurityHeaders(
+			webauthnLimit(http.HandlerFunc(authHandler.FinishWebAuthnMFA)),
+		),
+	)
+	mux.Handle(
+		"POST /auth/passkey/begin",
+		middlewares.SecurityHeaders(
+			webauthnLimit(http.HandlerFunc(authHandler.BeginPasskeyLogin)),
+		),
+	)
+	mux.Handle(
+		"POST /auth/passkey/finish",
+		middlewares.SecurityHeaders(
+			webauthnLimit(http.HandlerFunc(authHandler.FinishPasskeyLogin)),
+		),
+	)
+
+	// -------------------------
+	// SESSIONS ("me" or, for admins, any user)
+	// -------------------------
+
+	mux.Handle("GET /users/{id}/sessions", authed(http.HandlerFunc(sessionHandler.List)))
+	mux.Handle("DELETE /users/{id}/sessions", authed(http.HandlerFunc(sessionHandler.RevokeOthers)))
+	mux.Handle("DELETE /users/{id}/sessions/{sessionID}", authed(http.HandlerFunc(sessionHandler.Revoke)))
+
+	// -------------------------
+	// ADMIN ROUTES (RBAC)
+	// -------------------------
+
+	adminOnly := func(h http.HandlerFunc) http.Handler {
+		return authed(middlewares.RequireRole(models.RoleAdmin)(h))
+	}
+
+	mux.Handle("POST /admin/users", adminOnly(adminHandler.CreateUser))
+	mux.Handle("POST /admin/users/disable", adminOnly(adminHandler.DisableUser))
+	mux.Handle("PUT /admin/users/{id}/role", adminOnly(adminHandler.SetRole))
+	mux.Handle("GET /admin/audit-events", adminOnly(adminHandler.ListAuditEvents))
+	mux.Handle("GET /admin/lockouts", adminOnly(adminHandler.ListLockouts))
+	mux.Handle("DELETE /admin/lockouts/{scope}/{key}", adminOnly(adminHandler.ClearLockout))
+
+	mux.Handle("GET /admin/sla-policies", adminOnly(slaHandler.List))
+	mux.Handle("PUT /admin/sla-policies", adminOnly(slaHandler.Upsert))
+	mux.Handle("DELETE /admin/sla-policies/{id}", adminOnly(slaHandler.Delete))
+
+	mux.Handle("POST /admin/organizations", adminOnly(orgHandler.Create))
+	mux.Handle("GET /admin/organizations", adminOnly(orgHandler.List))
+	mux.Handle("PUT /admin/users/{id}/organization", adminOnly(orgHandler.SetMembership))
+
+	// -------------------------
+	// SUPPORT AGENTS
+	// -------------------------
+
+	mux.Handle(
+		"PUT /agents/{id}/availability",
+		authed(
+			middlewares.RequireRole(models.RoleSupport, models.RoleAdmin)(
+				http.HandlerFunc(agentHandler.SetAvailability),
 			),
 		),
 	)
 
+	// -------------------------
+	// TICKETS (AUTH REQUIRED)
+	// -------------------------
+
+	mux.Handle("POST /tickets", authed(http.HandlerFunc(ticketHandler.Create)))
+	mux.Handle("GET /tickets", authed(http.HandlerFunc(ticketHandler.List)))
+	mux.Handle("GET /tickets/search", authed(http.HandlerFunc(ticketHandler.Search)))
+	mux.Handle("GET /tickets/{id}", authed(http.HandlerFunc(ticketHandler.Get)))
+	mux.Handle("PATCH /tickets/{id}", authed(http.HandlerFunc(ticketHandler.Update)))
+	mux.Handle("POST /tickets/{id}/transitions", authed(http.HandlerFunc(ticketHandler.Transition)))
+	mux.Handle("GET /tickets/{id}/history", authed(http.HandlerFunc(ticketHandler.History)))
+	mux.Handle("POST /tickets/{id}/comments", authed(http.HandlerFunc(commentHandler.Create)))
+	mux.Handle("GET /tickets/{id}/comments", authed(http.HandlerFunc(commentHandler.List)))
+	mux.Handle("POST /tickets/{id}/attachments", authed(http.HandlerFunc(attachmentHandler.Upload)))
+	mux.Handle("GET /tickets/{id}/attachments", authed(http.HandlerFunc(attachmentHandler.List)))
+	mux.Handle(
+		"GET /tickets/{id}/attachments/{attachmentID}",
+		authed(http.HandlerFunc(attachmentHandler.Download)),
+	)
+	mux.Handle("DELETE /tickets/{id}", adminOnly(ticketHandler.Delete))
+
+	// -------------------------
+	// INBOUND MAIL (SHARED TOKEN)
+	// -------------------------
+
+	mux.Handle(
+		"POST /inbound/email",
+		middlewares.SecurityHeaders(
+			http.HandlerFunc(inboundMailHandler.Receive),
+		),
+	)
+
+	// -------------------------
+	// TOKEN VERIFICATION KEYS (PUBLIC)
+	// -------------------------
+
+	mux.Handle("GET /.well-known/jwks.json", http.HandlerFunc(jwksHandler.Keys))
+
 	// -------------------------
 	// HEALTH CHECK
 	// -------------------------
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// AssignmentService routes new tickets to support agents.
+type AssignmentService struct {
+	userRepo repositories.UserRepository
+	strategy string
+}
+
+func NewAssignmentService(userRepo repositories.UserRepository, strategy string) *AssignmentService {
+	if strategy != models.AssignLeastLoaded {
+		strategy = models.AssignRoundRobin
+	}
+	return &AssignmentService{userRepo: userRepo, strategy: strategy}
+}
+
+// Assign sets an assignee on an unassigned ticket. It leaves the ticket
+// unassigned when no agent is available.
+func (s *AssignmentService) Assign(ticket *models.Ticket) error {
+	if ticket.AssigneeID != nil {
+		return nil
+	}
+
+	agentID, err := s.userRepo.PickAgent(s.strategy)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ticket.AssigneeID = &agentID
+	return nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+const auditVerifyBatch = 1000
+
+// AuditSigner signs checkpoints of the audit hash chain with an Ed25519 key
+// kept outside the database, so whoever can rewrite audit_logs still can't
+// forge a matching checkpoint.
+type AuditSigner struct {
+	repo  repositories.AuditRepository
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewAuditSigner takes the base64-encoded 32-byte Ed25519 seed.
+func NewAuditSigner(repo repositories.AuditRepository, seed string) (*AuditSigner, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, errors.New("audit signing key must be a base64 32-byte ed25519 seed")
+	}
+
+	key := ed25519.NewKeyFromSeed(raw)
+	return &AuditSigner{repo: repo, key: key, keyID: auditKeyID(key.Public().(ed25519.PublicKey))}, nil
+}
+
+func (s *AuditSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *AuditSigner) KeyID() string {
+	return s.keyID
+}
+
+func auditKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func auditCheckpointPayload(c *models.AuditCheckpoint) []byte {
+	return []byte("ticketapp-audit-checkpoint\n" +
+		strconv.FormatInt(c.LastEventID, 10) + "\n" +
+		c.LastHash + "\n" +
+		c.CreatedAt.UTC().Format(time.RFC3339Nano))
+}
+
+// Checkpoint signs the current chain head. It returns nil when nothing was
+// logged since the previous checkpoint.
+func (s *AuditSigner) Checkpoint() (*models.AuditCheckpoint, error) {
+	head, err := s.repo.ChainHead()
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.repo.LatestCheckpoint()
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+	if latest != nil && latest.LastEventID >= head.ID {
+		return nil, nil
+	}
+
+	c := &models.AuditCheckpoint{
+		LastEventID: head.ID,
+		LastHash:    head.Hash,
+		KeyID:       s.keyID,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	c.Signature = ed25519.Sign(s.key, auditCheckpointPayload(c))
+
+	if err := s.repo.CreateCheckpoint(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// StartAuditCheckpoints signs a checkpoint every interval. Call once at
+// startup.
+func StartAuditCheckpoints(s *AuditSigner, interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			if _, err := s.Checkpoint(); err != nil {
+				log.Println("audit checkpoint:", err)
+			}
+		}
+	}()
+}
+
+// AuditVerifyOptions pins the chain to state recorded outside the database,
+// so deleting the newest entries together with their checkpoints is caught.
+type AuditVerifyOptions struct {
+	// Exported are checkpoints from an earlier export. Each must still
+	// match the chain.
+	Exported []models.AuditCheckpoint
+	// MinEventID is the lowest acceptable chain head, e.g. the head noted
+	// at the previous audit.
+	MinEventID int64
+}
+
+// AuditChainReport is the outcome of VerifyAuditChain. Broken is empty when
+// the chain is intact.
+type AuditChainReport struct {
+	Events      int
+	Unchained   int
+	Checkpoints int
+	// Head is the id of the newest chained event, 0 if there is none.
+	Head int64
+	// BrokenAt is the id of the first bad event, 0 if the problem is with
+	// a checkpoint or the chain's length.
+	BrokenAt int64
+	Broken   string
+}
+
+// expectedHead pairs a checkpoint with how to name it in a report.
+type expectedHead struct {
+	models.AuditCheckpoint
+	label string
+}
+
+// VerifyAuditChain walks audit_logs oldest first, recomputing each hash and
+// its link to the previous entry, and checks every stored and exported
+// checkpoint against the chain. Checkpoint signatures are only checked when
+// pub is set. It stops at the first problem.
+func VerifyAuditChain(
+	repo repositories.AuditRepository,
+	pub ed25519.PublicKey,
+	opts AuditVerifyOptions,
+) (*AuditChainReport, error) {
+	report := &AuditChainReport{}
+
+	checkpoints, err := repo.ListCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	report.Checkpoints = len(checkpoints)
+
+	expected := make([]expectedHead, 0, len(checkpoints)+len(opts.Exported))
+	for _, c := range checkpoints {
+		expected = append(expected, expectedHead{c, fmt.Sprintf("checkpoint %d", c.ID)})
+	}
+	minHead := opts.MinEventID
+	for _, c := range opts.Exported {
+		expected = append(expected, expectedHead{c, fmt.Sprintf("exported checkpoint %d", c.ID)})
+		minHead = max(minHead, c.LastEventID)
+	}
+
+	pending := make(map[int64][]expectedHead)
+	for _, c := range expected {
+		if pub != nil {
+			if c.KeyID != auditKeyID(pub) {
+				report.Broken = fmt.Sprintf("%s signed by unknown key %s", c.label, c.KeyID)
+				return report, nil
+			}
+			if !ed25519.Verify(pub, auditCheckpointPayload(&c.AuditCheckpoint), c.Signature) {
+				report.Broken = fmt.Sprintf("%s has an invalid signature", c.label)
+				return report, nil
+			}
+		}
+		pending[c.LastEventID] = append(pending[c.LastEventID], c)
+	}
+
+	var (
+		afterID int64
+		prev    string
+		chained bool
+	)
+	for {
+		events, err := repo.ListChain(afterID, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range events {
+			afterID = e.ID
+			report.Events++
+
+			if e.Hash == "" {
+				if chained {
+					report.BrokenAt, report.Broken = e.ID, "hash missing"
+					return report, nil
+				}
+				// written before the chain existed
+				report.Unchained++
+				continue
+			}
+			chained = true
+
+			if e.PrevHash != prev {
+				report.BrokenAt, report.Broken = e.ID, "does not link to the previous entry"
+				return report, nil
+			}
+
+			sum, err := e.ComputeHash()
+			if err != nil {
+				return nil, err
+			}
+			if sum != e.Hash {
+				report.BrokenAt, report.Broken = e.ID, "content does not match its hash"
+				return report, nil
+			}
+			prev = e.Hash
+			report.Head = e.ID
+
+			for _, c := range pending[e.ID] {
+				if c.LastHash != e.Hash {
+					report.BrokenAt = e.ID
+					report.Broken = "hash differs from " + c.label
+					return report, nil
+				}
+			}
+			delete(pending, e.ID)
+		}
+
+		if len(events) < auditVerifyBatch {
+			break
+		}
+	}
+
+	if report.Head < minHead {
+		report.Broken = fmt.Sprintf("chain ends at event %d, behind the expected head %d", report.Head, minHead)
+		return report, nil
+	}
+
+	for _, c := range expected {
+		if _, ok := pending[c.LastEventID]; ok {
+			report.Broken = fmt.Sprintf("event %d covered by %s is missing", c.LastEventID, c.label)
+			return report, nil
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// memAuditRepo chains events the way the Postgres repo does.
+type memAuditRepo struct {
+	repositories.AuditRepository
+	events      []models.AuditEvent
+	checkpoints []models.AuditCheckpoint
+}
+
+func (r *memAuditRepo) Log(e *models.AuditEvent) error {
+	e.ID = int64(len(r.events) + 1)
+	if n := len(r.events); n > 0 {
+		e.PrevHash = r.events[n-1].Hash
+	}
+	e.CreatedAt = time.Unix(0, 0).Add(time.Duration(e.ID) * time.Second).UTC()
+
+	var err error
+	if e.Hash, err = e.ComputeHash(); err != nil {
+		return err
+	}
+	r.events = append(r.events, *e)
+	return nil
+}
+
+func (r *memAuditRepo) ListChain(afterID int64, limit int) ([]models.AuditEvent, error) {
+	var out []models.AuditEvent
+	for _, e := range r.events {
+		if e.ID > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (r *memAuditRepo) ChainHead() (*models.AuditEvent, error) {
+	if len(r.events) == 0 {
+		return nil, repositories.ErrNotFound
+	}
+	e := r.events[len(r.events)-1]
+	return &e, nil
+}
+
+func (r *memAuditRepo) CreateCheckpoint(c *models.AuditCheckpoint) error {
+	c.ID = int64(len(r.checkpoints) + 1)
+	r.checkpoints = append(r.checkpoints, *c)
+	return nil
+}
+
+func (r *memAuditRepo) LatestCheckpoint() (*models.AuditCheckpoint, error) {
+	if len(r.checkpoints) == 0 {
+		return nil, repositories.ErrNotFound
+	}
+	c := r.checkpoints[len(r.checkpoints)-1]
+	return &c, nil
+}
+
+func (r *memAuditRepo) ListCheckpoints() ([]models.AuditCheckpoint, error) {
+	return append([]models.AuditCheckpoint(nil), r.checkpoints...), nil
+}
+
+func (r *memAuditRepo) log(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		if err := r.Log(&models.AuditEvent{Action: models.AuditLoginSucceeded, IP: "192.0.2.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// rewrite replaces events from id on with freshly chained ones, as someone
+// with write access to the table could.
+func (r *memAuditRepo) rewrite(t *testing.T, id int64) {
+	t.Helper()
+	n := len(r.events) - int(id) + 1
+	r.events = r.events[:id-1]
+	for range n {
+		if err := r.Log(&models.AuditEvent{Action: models.AuditLoginFailed, IP: "198.51.100.7"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (r *memAuditRepo) truncate(id int64) {
+	r.events = r.events[:id-1]
+	for i, c := range r.checkpoints {
+		if c.LastEventID >= id {
+			r.checkpoints = r.checkpoints[:i]
+			break
+		}
+	}
+}
+
+func testAuditSigner(t *testing.T, repo *memAuditRepo) *AuditSigner {
+	t.Helper()
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+	s, err := NewAuditSigner(repo, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuditSignerCheckpoint(t *testing.T) {
+	repo := &memAuditRepo{}
+	s := testAuditSigner(t, repo)
+
+	if c, err := s.Checkpoint(); err != nil || c != nil {
+		t.Fatalf("empty chain: checkpoint %+v, err %v", c, err)
+	}
+
+	repo.log(t, 3)
+	c, err := s.Checkpoint()
+	if err != nil || c == nil {
+		t.Fatalf("checkpoint %+v, err %v", c, err)
+	}
+	if c.LastEventID != 3 || c.LastHash != repo.events[2].Hash {
+		t.Errorf("checkpoint at %d/%s, want the head", c.LastEventID, c.LastHash)
+	}
+
+	if c, err := s.Checkpoint(); err != nil || c != nil {
+		t.Errorf("nothing new: checkpoint %+v, err %v", c, err)
+	}
+}
+
+func TestVerifyAuditChainPinned(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper runs after 5 events were logged, checkpointed and exported
+		tamper     func(t *testing.T, repo *memAuditRepo)
+		minEventID int64
+		noExport   bool
+		wantBroken string
+	}{
+		{name: "intact", tamper: func(t *testing.T, r *memAuditRepo) { r.log(t, 2) }},
+		{name: "min head reached", minEventID: 5},
+		{name: "min head not reached", minEventID: 9, noExport: true, wantBroken: "behind the expected head 9"},
+		{
+			name:       "newest events and checkpoint deleted",
+			tamper:     func(_ *testing.T, r *memAuditRepo) { r.truncate(4) },
+			wantBroken: "chain ends at event 3, behind the expected head 5",
+		},
+		{
+			name:       "deletion only caught by min head without an export",
+			tamper:     func(_ *testing.T, r *memAuditRepo) { r.truncate(4) },
+			noExport:   true,
+			minEventID: 5,
+			wantBroken: "behind the expected head 5",
+		},
+		{
+			name: "deleted and logged past the exported head",
+			tamper: func(t *testing.T, r *memAuditRepo) {
+				r.truncate(4)
+				for range 4 {
+					_ = r.Log(&models.AuditEvent{Action: models.AuditUserCreated})
+				}
+			},
+			wantBroken: "hash differs from exported checkpoint 1",
+		},
+		{
+			name: "rechained and checkpoints replaced",
+			tamper: func(t *testing.T, r *memAuditRepo) {
+				r.rewrite(t, 2)
+				r.checkpoints = nil
+			},
+			wantBroken: "hash differs from exported checkpoint 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memAuditRepo{}
+			s := testAuditSigner(t, repo)
+			repo.log(t, 5)
+			if _, err := s.Checkpoint(); err != nil {
+				t.Fatal(err)
+			}
+			exported, _ := repo.ListCheckpoints()
+
+			if tt.tamper != nil {
+				tt.tamper(t, repo)
+			}
+
+			opts := AuditVerifyOptions{MinEventID: tt.minEventID}
+			if !tt.noExport {
+				opts.Exported = exported
+			}
+			report, err := VerifyAuditChain(repo, s.PublicKey(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantBroken == "" {
+				if report.Broken != "" {
+					t.Errorf("Broken = %q, want an intact chain", report.Broken)
+				}
+				return
+			}
+			if !strings.Contains(report.Broken, tt.wantBroken) {
+				t.Errorf("Broken = %q, want %q", report.Broken, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditChainRejectsForgedExport(t *testing.T) {
+	repo := &memAuditRepo{}
+	s := testAuditSigner(t, repo)
+	repo.log(t, 3)
+	if _, err := s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	// an export edited to point at another head no longer verifies
+	forged, _ := repo.ListCheckpoints()
+	forged[0].LastEventID = 2
+	forged[0].LastHash = repo.events[1].Hash
+
+	report, err := VerifyAuditChain(repo, s.PublicKey(), AuditVerifyOptions{Exported: forged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.Broken, "exported checkpoint 1 has an invalid signature") {
+		t.Errorf("Broken = %q", report.Broken)
+	}
+}
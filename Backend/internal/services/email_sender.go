@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailMessage is a rendered email ready for delivery.
+type EmailMessage struct {
+	To      string
+	ReplyTo string // optional
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// EmailSender delivers a single message.
+type EmailSender interface {
+	Send(msg EmailMessage) error
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Timeout bounds the whole SMTP conversation.
+	Timeout time.Duration
+	// AllowPlaintext lets mail go out unencrypted when the relay doesn't
+	// offer STARTTLS, e.g. a local mail catcher. Off by default.
+	AllowPlaintext bool
+}
+
+// SMTPSender delivers mail through an SMTP relay over STARTTLS. A relay
+// that doesn't offer it is refused unless AllowPlaintext is set.
+type SMTPSender struct {
+	cfg SMTPConfig
+	tls *tls.Config
+}
+
+func NewSMTPSender(cfg SMTPConfig) (*SMTPSender, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("smtp host and from address are required")
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &SMTPSender{
+		cfg: cfg,
+		tls: &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+func (s *SMTPSender) Send(msg EmailMessage) error {
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	body, err := buildMIME(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)), s.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(s.tls); err != nil {
+			return err
+		}
+	} else if !s.cfg.AllowPlaintext {
+		return errors.New("smtp server does not offer STARTTLS")
+	}
+
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// buildMIME renders a multipart/alternative message with text and HTML parts.
+func buildMIME(from, to *mail.Address, msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	// strip CR/LF so a subject can never inject extra headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	headers := []string{
+		"From: " + from.String(),
+		"To: " + to.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	if msg.ReplyTo != "" {
+		replyTo, err := mail.ParseAddress(msg.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply-to: %w", err)
+		}
+		headers = append(headers, "Reply-To: "+replyTo.String())
+	}
+	var out bytes.Buffer
+	out.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// LogSender is for local development without an SMTP server. It logs only
+// the envelope, never the body, so links and secrets stay out of the log.
+type LogSender struct{}
+
+func (LogSender) Send(msg EmailMessage) error {
+	log.Printf("[DEV EMAIL] To=%s Subject=%q (body not logged)", msg.To, msg.Subject)
+	return nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a just-enough SMTP relay: EHLO, optional STARTTLS, AUTH PLAIN,
+// one transaction per connection.
+type fakeSMTP struct {
+	ln         net.Listener
+	tls        *tls.Config
+	startTLS   bool
+	rejectRcpt bool
+
+	mu   sync.Mutex
+	mail []fakeSMTPMail
+}
+
+type fakeSMTPMail struct {
+	from, to, auth, data string
+	overTLS              bool
+}
+
+func newFakeSMTP(t *testing.T, startTLS, rejectRcpt bool) (*fakeSMTP, *x509.CertPool) {
+	t.Helper()
+
+	cert, pool := selfSignedCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeSMTP{
+		ln:         ln,
+		tls:        &tls.Config{Certificates: []tls.Certificate{cert}},
+		startTLS:   startTLS,
+		rejectRcpt: rejectRcpt,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, pool
+}
+
+func (f *fakeSMTP) port() int {
+	return f.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) received() []fakeSMTPMail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeSMTPMail(nil), f.mail...)
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	tp := textproto.NewConn(conn)
+	var m fakeSMTPMail
+	reply := func(line string) { _ = tp.PrintfLine("%s", line) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			reply("250-fake")
+			if f.startTLS && !m.overTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tc := tls.Server(conn, f.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, tp, m.overTLS = tc, textproto.NewConn(tc), true
+		case "AUTH":
+			m.auth = arg
+			reply("235 ok")
+		case "MAIL":
+			m.from = arg
+			reply("250 ok")
+		case "RCPT":
+			if f.rejectRcpt {
+				reply("550 no such user")
+				continue
+			}
+			m.to = arg
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.data = string(data)
+			f.mu.Lock()
+			f.mail = append(f.mail, m)
+			f.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestSMTPSender(t *testing.T) {
+	msg := EmailMessage{
+		To:      "Jane <jane@example.com>",
+		ReplyTo: "support+abc@example.com",
+		Subject: "Hello\r\nBcc: evil@example.com",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+
+	tests := []struct {
+		name           string
+		startTLS       bool
+		rejectRcpt     bool
+		allowPlaintext bool
+		trusted        bool // whether the sender trusts the fake's certificate
+		username       string
+		wantErr        string
+		wantTLS        bool
+	}{
+		{name: "starttls with auth", startTLS: true, trusted: true, username: "mailer", wantTLS: true},
+		{name: "starttls is used even when plaintext is allowed", startTLS: true, trusted: true, allowPlaintext: true, wantTLS: true},
+		{name: "no starttls is refused by default", wantErr: "does not offer STARTTLS"},
+		{name: "no starttls with plaintext allowed", allowPlaintext: true},
+		{name: "untrusted certificate", startTLS: true, wantErr: "certificate"},
+		{name: "recipient rejected", startTLS: true, trusted: true, rejectRcpt: true, wantErr: "550"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, pool := newFakeSMTP(t, tt.startTLS, tt.rejectRcpt)
+
+			s, err := NewSMTPSender(SMTPConfig{
+				Host:           "127.0.0.1",
+				Port:           fake.port(),
+				Username:       tt.username,
+				Password:       "secret",
+				From:           "Support <support@example.com>",
+				Timeout:        5 * time.Second,
+				AllowPlaintext: tt.allowPlaintext,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.trusted {
+				s.tls.RootCAs = pool
+			}
+
+			err = s.Send(msg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() err = %v, want one containing %q", err, tt.wantErr)
+				}
+				if got := fake.received(); len(got) != 0 {
+					t.Fatalf("%d messages delivered despite the error", len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send(): %v", err)
+			}
+
+			got := fake.received()
+			if len(got) != 1 {
+				t.Fatalf("got %d messages, want 1", len(got))
+			}
+			m := got[0]
+
+			if m.overTLS != tt.wantTLS {
+				t.Errorf("delivered over TLS = %v, want %v", m.overTLS, tt.wantTLS)
+			}
+			if m.from != "FROM:<support@example.com>" || m.to != "TO:<jane@example.com>" {
+				t.Errorf("envelope = %q -> %q", m.from, m.to)
+			}
+			if tt.username != "" {
+				creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(m.auth, "PLAIN "))
+				if string(creds) != "\x00mailer\x00secret" {
+					t.Errorf("AUTH = %q", creds)
+				}
+			}
+
+			header, _, _ := strings.Cut(m.data, "\r\n\r\n")
+			for _, want := range []string{
+				"To: \"Jane\" <jane@example.com>",
+				"Reply-To: <support+abc@example.com>",
+				"Subject: Hello  Bcc: evil@example.com",
+			} {
+				if !strings.Contains(header, want) {
+					t.Errorf("header is missing %q:\n%s", want, header)
+				}
+			}
+			if strings.Contains(header, "\r\nBcc:") {
+				t.Error("subject injected a Bcc header")
+			}
+			for _, part := range []string{"plain body", "<p>html body</p>"} {
+				if !strings.Contains(m.data, part) {
+					t.Errorf("body is missing %q", part)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSMTPSenderDefaults(t *testing.T) {
+	s, err := NewSMTPSender(SMTPConfig{Host: "smtp.example.com", From: "support@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.cfg.Port != 587 || s.cfg.AllowPlaintext {
+		t.Errorf("defaults = port %d, plaintext %v; want 587, false", s.cfg.Port, s.cfg.AllowPlaintext)
+	}
+	if s.tls.ServerName != "smtp.example.com" || s.tls.MinVersion < tls.VersionTLS12 {
+		t.Errorf("tls config = %+v", s.tls)
+	}
+
+	for _, cfg := range []SMTPConfig{{From: "support@example.com"}, {Host: "smtp.example.com"}, {Host: "h", From: "not an address"}} {
+		if _, err := NewSMTPSender(cfg); err == nil {
+			t.Errorf("NewSMTPSender(%+v) = nil error", cfg)
+		}
+	}
+}
@@ -1,40 +1,192 @@
 package services
 
-import "log"
+import (
+	"log"
+	"time"
 
-// EmailService is a temporary implementation.
-// Replace this with Mailtrap / SES later.
-type EmailService struct{}
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
 
-// NewEmailService creates a temp email service
-func NewEmailService() *EmailService {
-	return &EmailService{}
+	"github.com/google/uuid"
+)
+
+const (
+	outboxBatchSize   = 20
+	outboxLease       = 5 * time.Minute
+	outboxMaxAttempts = 10
+	outboxMaxBackoff  = 6 * time.Hour
+)
+
+// EmailService renders emails and queues them in the Postgres outbox, so a
+// message survives an SMTP outage or a restart. StartEmailOutboxWorker
+// delivers the queue.
+type EmailService struct {
+	outbox    repositories.EmailOutboxRepository
+	templates *emailTemplates
+	baseURL   string
+	replies   *ReplyAddresses
 }
 
-// SendUserInvite simulates sending onboarding email
+// NewEmailService creates an email service. baseURL is the public address
+// of the frontend, used to build links. replies, when set, gives ticket
+// notifications a per-recipient reply address.
+func NewEmailService(
+	outbox repositories.EmailOutboxRepository,
+	baseURL string,
+	replies *ReplyAddresses,
+) (*EmailService, error) {
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailService{
+		outbox:    outbox,
+		templates: templates,
+		baseURL:   baseURL,
+		replies:   replies,
+	}, nil
+}
+
+func (e *EmailService) enqueue(to, replyTo, subject, template string, data any) error {
+	text, html, err := e.templates.render(template, data)
+	if err != nil {
+		return err
+	}
+
+	return e.outbox.Enqueue(&models.OutboxEmail{
+		ID:      uuid.New(),
+		To:      to,
+		ReplyTo: replyTo,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// SendUserInvite sends a new user a link to set their first password.
 func (e *EmailService) SendUserInvite(
 	to string,
 	username string,
-	tempPassword string,
+	token string,
+	expiresIn time.Duration,
 ) error {
-	log.Printf(
-		"[DEV EMAIL] To=%s Username=%s TempPassword=%s",
-		to,
-		username,
-		tempPassword,
-	)
-	return nil
+	return e.enqueue(to, "", "Your support portal account", "invite", map[string]any{
+		"Username":  username,
+		"Link":      e.baseURL + "/reset-password?token=" + token,
+		"ExpiresIn": expiresIn.String(),
+	})
 }
 
-// SendPasswordReset simulates password reset email
+// SendPasswordReset sends a password reset link.
 func (e *EmailService) SendPasswordReset(
 	to string,
-	resetLink string,
+	token string,
+	expiresIn time.Duration,
+) error {
+	return e.enqueue(to, "", "Reset your password", "password_reset", map[string]any{
+		"Link":      e.baseURL + "/reset-password?token=" + token,
+		"ExpiresIn": expiresIn.String(),
+	})
+}
+
+// SendAccountLocked tells a user their account was locked after repeated
+// failed logins.
+func (e *EmailService) SendAccountLocked(
+	to string,
+	failures int,
+	lockedFor time.Duration,
 ) error {
-	log.Printf(
-		"[DEV EMAIL] To=%s ResetLink=%s",
-		to,
-		resetLink,
-	)
-	return nil
+	return e.enqueue(to, "", "Your account was locked", "account_locked", map[string]any{
+		"Failures":  failures,
+		"LockedFor": lockedFor.String(),
+	})
+}
+
+// SendTicketNotification tells a user about activity on a ticket. The
+// subject carries the ticket reference so replies thread back onto it, and
+// the reply address identifies the recipient to mail ingestion.
+func (e *EmailService) SendTicketNotification(
+	to *models.User,
+	ticket *models.Ticket,
+	headline string,
+	body string,
+) error {
+	ref := TicketReference(ticket.ID)
+
+	var replyTo string
+	if e.replies != nil {
+		replyTo = e.replies.For(ticket.ID, to.ID)
+	}
+
+	return e.enqueue(to.Email, replyTo, ticket.Subject+" "+ref, "ticket_notification", map[string]any{
+		"Headline":  headline,
+		"Subject":   ticket.Subject,
+		"Status":    ticket.Status,
+		"Body":      body,
+		"Link":      e.baseURL + "/tickets/" + ticket.ID.String(),
+		"Reference": ref,
+	})
+}
+
+// StartEmailOutboxWorker delivers queued emails, retrying failures with
+// exponential backoff until outboxMaxAttempts. Call once at startup.
+func StartEmailOutboxWorker(
+	outbox repositories.EmailOutboxRepository,
+	sender EmailSender,
+	interval time.Duration,
+) {
+	go func() {
+		for {
+			emails, err := outbox.ClaimDue(outboxBatchSize, outboxLease)
+			if err != nil {
+				log.Println("email outbox:", err)
+			}
+
+			for _, email := range emails {
+				deliverOutboxEmail(outbox, sender, email)
+			}
+
+			if len(emails) < outboxBatchSize {
+				time.Sleep(interval)
+			}
+		}
+	}()
+}
+
+func deliverOutboxEmail(
+	outbox repositories.EmailOutboxRepository,
+	sender EmailSender,
+	email models.OutboxEmail,
+) {
+	err := sender.Send(EmailMessage{
+		To:      email.To,
+		ReplyTo: email.ReplyTo,
+		Subject: email.Subject,
+		Text:    email.Text,
+		HTML:    email.HTML,
+	})
+	if err == nil {
+		if err := outbox.MarkSent(email.ID); err != nil {
+			log.Println("email outbox:", err)
+		}
+		return
+	}
+
+	var next *time.Time
+	if attempt := email.Attempts + 1; attempt < outboxMaxAttempts {
+		backoff := time.Minute << attempt
+		if backoff > outboxMaxBackoff {
+			backoff = outboxMaxBackoff
+		}
+		t := time.Now().Add(backoff)
+		next = &t
+		log.Printf("email outbox: send %s failed (attempt %d), retrying in %s: %v", email.ID, attempt, backoff, err)
+	} else {
+		log.Printf("email outbox: giving up on %s after %d attempts: %v", email.ID, attempt, err)
+	}
+
+	if err := outbox.MarkFailed(email.ID, err.Error(), next); err != nil {
+		log.Println("email outbox:", err)
+	}
 }
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/email/*.tmpl
+var emailTemplateFS embed.FS
+
+// emailTemplates renders the text and HTML variants of each email.
+type emailTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func loadEmailTemplates() (*emailTemplates, error) {
+	text, err := texttemplate.ParseFS(emailTemplateFS, "templates/email/*.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.ParseFS(emailTemplateFS, "templates/email/*.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailTemplates{text: text, html: html}, nil
+}
+
+// render executes name.txt.tmpl and name.html.tmpl with data.
+func (t *emailTemplates) render(name string, data any) (string, string, error) {
+	var text, html bytes.Buffer
+
+	if err := t.text.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return "", "", err
+	}
+	if err := t.html.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return "", "", err
+	}
+
+	return text.String(), html.String(), nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxMIMEDepth bounds multipart nesting so a crafted message can't recurse forever.
+const maxMIMEDepth = 10
+
+var ticketRefPattern = regexp.MustCompile(`\[ticket:([0-9a-fA-F-]{36})\]`)
+
+// TicketReference is the token put in outgoing subjects so replies can be
+// threaded back onto the ticket.
+func TicketReference(id uuid.UUID) string {
+	return "[ticket:" + id.String() + "]"
+}
+
+// ParseTicketReference finds a ticket reference token in a subject.
+func ParseTicketReference(subject string) (uuid.UUID, bool) {
+	m := ticketRefPattern.FindStringSubmatch(subject)
+	if m == nil {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(m[1])
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// InboundMessage is the part of an RFC 5322 message that ticket ingestion uses.
+type InboundMessage struct {
+	MessageID     string
+	From          string // bare address, lower-cased
+	Subject       string
+	Text          string
+	AutoSubmitted bool // auto-replies, bounces, list traffic
+	Attachments   []InboundAttachment
+	// Recipients are the bare, lower-cased addresses the message was sent to.
+	Recipients []string
+	// AuthResults is the topmost Authentication-Results header, the one the
+	// receiving MTA added.
+	AuthResults string
+}
+
+type InboundAttachment struct {
+	Filename string
+	Data     []byte
+}
+
+// ParseInboundMessage reads a raw message. Attachments larger than
+// maxAttachment are dropped rather than failing the whole message.
+func ParseInboundMessage(r io.Reader, maxAttachment int64) (*InboundMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("parse from: %w", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	in := &InboundMessage{
+		MessageID:   strings.TrimSpace(msg.Header.Get("Message-Id")),
+		From:        strings.ToLower(from.Address),
+		Subject:     strings.TrimSpace(subject),
+		AuthResults: msg.Header.Get("Authentication-Results"),
+	}
+
+	for _, name := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, v := range msg.Header[name] {
+			addrs, err := mail.ParseAddressList(v)
+			if err != nil {
+				continue
+			}
+			for _, a := range addrs {
+				in.Recipients = append(in.Recipients, strings.ToLower(a.Address))
+			}
+		}
+	}
+
+	auto := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	in.AutoSubmitted = (auto != "" && auto != "no") ||
+		precedence == "bulk" || precedence == "junk" || precedence == "list"
+
+	p := &mimeWalker{msg: in, maxAttachment: maxAttachment}
+	if err := p.walk(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), "", msg.Body, 0); err != nil {
+		return nil, err
+	}
+
+	if in.Text == "" && p.html != "" {
+		in.Text = htmlToText(p.html)
+	}
+	in.Text = strings.ToValidUTF8(strings.TrimSpace(in.Text), "")
+
+	return in, nil
+}
+
+type mimeWalker struct {
+	msg           *InboundMessage
+	html          string
+	maxAttachment int64
+}
+
+func (p *mimeWalker) walk(contentType, encoding, disposition string, body io.Reader, depth int) error {
+	if depth > maxMIMEDepth {
+		return errors.New("message nested too deeply")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read mime part: %w", err)
+			}
+
+			// NextPart already undoes quoted-printable and drops the header
+			err = p.walk(
+				part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"),
+				part,
+				depth+1,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	body = decodeTransfer(encoding, body)
+
+	dispType, dispParams, _ := mime.ParseMediaType(disposition)
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	isAttachment := dispType == "attachment" || filename != "" ||
+		!(mediaType == "text/plain" || mediaType == "text/html")
+
+	if isAttachment {
+		data, err := io.ReadAll(io.LimitReader(body, p.maxAttachment+1))
+		if err != nil {
+			return fmt.Errorf("read attachment: %w", err)
+		}
+		if int64(len(data)) > p.maxAttachment {
+			log.Printf("inbound mail %s: dropping oversized attachment %q", p.msg.MessageID, filename)
+			return nil
+		}
+		p.msg.Attachments = append(p.msg.Attachments, InboundAttachment{Filename: filename, Data: data})
+		return nil
+	}
+
+	text, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+
+	switch {
+	case mediaType == "text/plain" && p.msg.Text == "":
+		p.msg.Text = string(text)
+	case mediaType == "text/html" && p.html == "":
+		p.html = string(text)
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// htmlToText is a crude fallback for HTML-only mail; good enough to keep
+// the words searchable and readable.
+func htmlToText(html string) string {
+	text := htmlBreakPattern.ReplaceAllString(html, "\n")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	for _, r := range [][2]string{{"&nbsp;", " "}, {"&lt;", "<"}, {"&gt;", ">"}, {"&quot;", `"`}, {"&amp;", "&"}} {
+		text = strings.ReplaceAll(text, r[0], r[1])
+	}
+	return text
+}
+
+var authResultsCommentPattern = regexp.MustCompile(`\([^()]*\)`)
+
+// SenderAuthenticated reports whether results, the topmost
+// Authentication-Results header, was stamped by authservID and records a
+// DKIM, SPF or DMARC pass for exactly the domain of from. The receiving MTA
+// prepends its header, so forged copies further down are never consulted.
+func SenderAuthenticated(results, authservID, from string) bool {
+	_, fromDomain, ok := strings.Cut(from, "@")
+	if !ok || authservID == "" {
+		return false
+	}
+
+	parts := strings.Split(authResultsCommentPattern.ReplaceAllString(results, ""), ";")
+	if id := strings.Fields(parts[0]); len(id) == 0 || !strings.EqualFold(id[0], authservID) {
+		return false
+	}
+
+	for _, part := range parts[1:] {
+		fields := strings.Fields(strings.ToLower(part))
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, _ := strings.Cut(fields[0], "=")
+		if result != "pass" {
+			continue
+		}
+
+		props := map[string]string{}
+		for _, f := range fields[1:] {
+			if k, v, ok := strings.Cut(f, "="); ok {
+				props[k] = strings.Trim(v, `"`)
+			}
+		}
+
+		var domain string
+		switch method {
+		case "dkim":
+			domain = props["header.d"]
+		case "spf":
+			domain = props["smtp.mailfrom"]
+			if _, d, ok := strings.Cut(domain, "@"); ok {
+				domain = d
+			}
+		case "dmarc":
+			domain = props["header.from"]
+		}
+		if domain != "" && domain == fromDomain {
+			return true
+		}
+	}
+	return false
+}
+
+var replyHeaderPattern = regexp.MustCompile(`(?m)^On .+wrote:\s*$`)
+
+// StripQuotedReply drops the quoted history mail clients append to replies.
+func StripQuotedReply(text string) string {
+	if loc := replyHeaderPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+
+	var out bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return strings.TrimSpace(out.String())
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseInboundMessage(t *testing.T) {
+	// header lines, a blank line, then the body; \n is turned into CRLF
+	msg := func(headers, body string) string {
+		return strings.ReplaceAll("From: Jane <Jane@Example.com>\nMessage-Id: <m1@example.com>\n"+headers+"\n\n"+body, "\n", "\r\n")
+	}
+
+	type attachment struct{ name, data string }
+
+	tests := []struct {
+		name        string
+		raw         string
+		wantText    string
+		wantAttach  []attachment
+		wantAuto    bool
+		wantErr     bool
+		wantSubject string
+	}{
+		{
+			name:     "plain text",
+			raw:      msg("Subject: Hi", "hello there"),
+			wantText: "hello there",
+		},
+		{
+			name:        "encoded subject",
+			raw:         msg("Subject: =?UTF-8?B?w7xtbGF1dA==?=", "x"),
+			wantText:    "x",
+			wantSubject: "ümlaut",
+		},
+		{
+			name:     "quoted-printable single part",
+			raw:      msg("Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable", "caf=C3=A9 au lait"),
+			wantText: "café au lait",
+		},
+		{
+			name: "alternative prefers text over html",
+			raw: msg("Content-Type: multipart/alternative; boundary=b1", `--b1
+Content-Type: text/plain
+
+plain version
+--b1
+Content-Type: text/html
+
+<p>html version</p>
+--b1--`),
+			wantText: "plain version",
+		},
+		{
+			name: "html only falls back to stripped text",
+			raw: msg("Content-Type: multipart/alternative; boundary=b1", `--b1
+Content-Type: text/html
+
+<p>one</p><div>two &amp; three</div>
+--b1--`),
+			wantText: "one\ntwo & three",
+		},
+		{
+			name: "nested mixed with base64 attachment",
+			raw: msg("Content-Type: multipart/mixed; boundary=outer", `--outer
+Content-Type: multipart/alternative; boundary=inner
+
+--inner
+Content-Type: text/plain
+
+body text
+--inner--
+--outer
+Content-Type: application/pdf
+Content-Disposition: attachment; filename="report.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQ=
+--outer--`),
+			wantText:   "body text",
+			wantAttach: []attachment{{"report.pdf", "%PDF-1.4"}},
+		},
+		{
+			name: "oversized attachment is dropped",
+			raw: msg("Content-Type: multipart/mixed; boundary=b1", `--b1
+Content-Type: text/plain
+
+see attached
+--b1
+Content-Type: application/octet-stream; name="big.bin"
+
+`+strings.Repeat("x", 100)+`
+--b1--`),
+			wantText: "see attached",
+		},
+		{
+			name:     "auto-submitted",
+			raw:      msg("Auto-Submitted: auto-replied", "out of office"),
+			wantText: "out of office",
+			wantAuto: true,
+		},
+		{
+			name:     "list traffic",
+			raw:      msg("Precedence: list", "digest"),
+			wantText: "digest",
+			wantAuto: true,
+		},
+		{
+			name:    "nested too deeply",
+			raw:     msg("Content-Type: multipart/mixed; boundary=b0", nestedParts(maxMIMEDepth+2)),
+			wantErr: true,
+		},
+		{
+			name:    "missing from",
+			raw:     "Subject: x\r\n\r\nbody",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := ParseInboundMessage(strings.NewReader(tt.raw), 64)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if in.From != "jane@example.com" {
+				t.Errorf("From = %q", in.From)
+			}
+			if in.MessageID != "<m1@example.com>" {
+				t.Errorf("MessageID = %q", in.MessageID)
+			}
+			if tt.wantSubject != "" && in.Subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", in.Subject, tt.wantSubject)
+			}
+			if in.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", in.Text, tt.wantText)
+			}
+			if in.AutoSubmitted != tt.wantAuto {
+				t.Errorf("AutoSubmitted = %v, want %v", in.AutoSubmitted, tt.wantAuto)
+			}
+
+			if len(in.Attachments) != len(tt.wantAttach) {
+				t.Fatalf("got %d attachments, want %d", len(in.Attachments), len(tt.wantAttach))
+			}
+			for i, want := range tt.wantAttach {
+				got := in.Attachments[i]
+				if got.Filename != want.name || string(got.Data) != want.data {
+					t.Errorf("attachment %d = {%q %q}, want {%q %q}", i, got.Filename, got.Data, want.name, want.data)
+				}
+			}
+		})
+	}
+}
+
+// nestedParts builds n levels of multipart/mixed, boundaries b1..bn, inside
+// a body whose own boundary is b0.
+func nestedParts(n int) string {
+	var open, closing strings.Builder
+	for i := 1; i <= n; i++ {
+		open.WriteString("--b" + strconv.Itoa(i-1) + "\nContent-Type: multipart/mixed; boundary=b" + strconv.Itoa(i) + "\n\n")
+	}
+	open.WriteString("--b" + strconv.Itoa(n) + "\nContent-Type: text/plain\n\ndeep\n")
+	for i := n; i >= 0; i-- {
+		closing.WriteString("--b" + strconv.Itoa(i) + "--\n")
+	}
+	return open.String() + closing.String()
+}
+
+func TestStripQuotedReply(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Thanks, fixed.", "Thanks, fixed."},
+		{"Thanks!\n\nOn Mon, 1 Jan 2024, Support wrote:\n> earlier\n> text", "Thanks!"},
+		{"Answer inline\n> quoted line\nmore", "Answer inline\nmore"},
+		{"> only quoted", ""},
+	}
+
+	for _, tt := range tests {
+		if got := StripQuotedReply(tt.in); got != tt.want {
+			t.Errorf("StripQuotedReply(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSenderAuthenticated(t *testing.T) {
+	const from = "jane@example.com"
+
+	tests := []struct {
+		name    string
+		results string
+		want    bool
+	}{
+		{"dkim pass", "mx.ours.test; dkim=pass header.d=example.com header.s=sel", true},
+		{"spf pass", "mx.ours.test; spf=pass smtp.mailfrom=bounce@example.com", true},
+		{"dmarc pass", "mx.ours.test; dmarc=pass (p=reject) header.from=example.com", true},
+		{"version and comments", "mx.ours.test 1; (checked) spf=fail smtp.mailfrom=x@evil.test; dkim=pass (good sig) header.d=Example.COM", true},
+		{"no results", "", false},
+		{"other authserv-id", "mx.evil.test; dkim=pass header.d=example.com", false},
+		{"authserv-id only in a comment", "(mx.ours.test) mx.evil.test; dkim=pass header.d=example.com", false},
+		{"dkim for another domain", "mx.ours.test; dkim=pass header.d=evil.test", false},
+		{"parent domain is not aligned", "mx.ours.test; dkim=pass header.d=com", false},
+		{"subdomain is not aligned", "mx.ours.test; dkim=pass header.d=mail.example.com", false},
+		{"dkim fail", "mx.ours.test; dkim=fail header.d=example.com", false},
+		{"spf softfail", "mx.ours.test; spf=softfail smtp.mailfrom=example.com", false},
+		{"none", "mx.ours.test; none", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SenderAuthenticated(tt.results, "mx.ours.test", from); got != tt.want {
+				t.Errorf("SenderAuthenticated(%q) = %v, want %v", tt.results, got, tt.want)
+			}
+		})
+	}
+
+	if SenderAuthenticated("; dkim=pass header.d=example.com", "", from) {
+		t.Error("an empty authserv-id must never match")
+	}
+}
+
+func TestParseInboundMessageRecipients(t *testing.T) {
+	raw := strings.ReplaceAll(`Authentication-Results: mx.ours.test; dkim=pass header.d=example.com
+Authentication-Results: mx.ours.test; dkim=pass header.d=forged.test
+From: jane@example.com
+To: Support <Support+ABC@Example.com>, other@example.com
+Cc: cc@example.com
+Delivered-To: support+abc@example.com
+
+body`, "\n", "\r\n")
+
+	in, err := ParseInboundMessage(strings.NewReader(raw), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"support+abc@example.com", "other@example.com", "cc@example.com", "support+abc@example.com"}
+	if strings.Join(in.Recipients, ",") != strings.Join(want, ",") {
+		t.Errorf("Recipients = %q, want %q", in.Recipients, want)
+	}
+	if !strings.Contains(in.AuthResults, "header.d=example.com") {
+		t.Errorf("AuthResults = %q, want the topmost header", in.AuthResults)
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// JWKSMaxAge is how long verifiers may cache /.well-known/jwks.json. A new
+// key is published this long before it signs anything, so a verifier with
+// a stale cache never meets an unknown kid.
+const JWKSMaxAge = 5 * time.Minute
+
+type JWTKeyConfig struct {
+	// Algorithm for new keys: "RS256" or "EdDSA".
+	Algorithm string
+	// RotateEvery is how often a new signing key is introduced.
+	RotateEvery time.Duration
+	// Overlap is how long a retired key is still accepted. A replica may
+	// sign with it for up to PollInterval after its successor is due, so it
+	// is at least AccessTokenTTL plus PollInterval.
+	Overlap time.Duration
+	// PollInterval is how often StartJWTKeyRotation checks the shared keys;
+	// defaults to a minute and must stay under JWKSMaxAge.
+	PollInterval time.Duration
+	// KEK is the 32-byte key-encryption key private keys are sealed with
+	// (AES-256-GCM) before they reach the database. Keep it out of the
+	// database, e.g. in the environment or a secret manager.
+	KEK []byte
+}
+
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	private    any
+	public     crypto.PublicKey
+	activeFrom time.Time
+}
+
+// JWTKeyRing holds the keys access tokens are signed and verified with. An
+// asymmetric ring is backed by the database so all replicas sign with, and
+// publish, the same keys; an HMAC ring wraps the legacy shared secret.
+type JWTKeyRing struct {
+	repo  repositories.JWTKeyRepository
+	cfg   JWTKeyConfig
+	kek   cipher.AEAD
+	kekID string
+
+	mu   sync.RWMutex
+	keys []signingKey // newest first
+}
+
+// NewHMACKeyRing signs HS256 with one shared secret, without a kid. Nothing
+// is published in the JWKS and rotating the secret invalidates all tokens.
+func NewHMACKeyRing(secret string) *JWTKeyRing {
+	return &JWTKeyRing{
+		keys: []signingKey{{
+			method:  jwt.SigningMethodHS256,
+			private: []byte(secret),
+			public:  []byte(secret),
+		}},
+	}
+}
+
+func NewJWTKeyRing(repo repositories.JWTKeyRepository, cfg JWTKeyConfig) (*JWTKeyRing, error) {
+	if cfg.Algorithm != "RS256" && cfg.Algorithm != "EdDSA" {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+	if cfg.RotateEvery <= 0 {
+		cfg.RotateEvery = 7 * 24 * time.Hour
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.PollInterval >= JWKSMaxAge {
+		return nil, fmt.Errorf("jwt key poll interval must be under %s", JWKSMaxAge)
+	}
+	if minOverlap := AccessTokenTTL + cfg.PollInterval; cfg.Overlap < minOverlap {
+		cfg.Overlap = minOverlap
+	}
+	if len(cfg.KEK) != 32 {
+		return nil, errors.New("jwt key encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(cfg.KEK)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(cfg.KEK)
+
+	ring := &JWTKeyRing{repo: repo, cfg: cfg, kek: kek, kekID: hex.EncodeToString(sum[:8])}
+	if err := ring.Rotate(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Rotate reloads the shared keys and adds a new one when the newest is due
+// for rotation. Replicas racing here may each add a key; that is harmless.
+func (k *JWTKeyRing) Rotate() error {
+	if k.repo == nil {
+		return nil
+	}
+
+	stored, err := k.repo.ListJWTKeys()
+	if err != nil {
+		return err
+	}
+
+	if len(stored) == 0 ||
+		stored[0].Algorithm != k.cfg.Algorithm ||
+		time.Since(stored[0].CreatedAt) >= k.cfg.RotateEvery {
+		key, err := k.generate()
+		if err != nil {
+			return err
+		}
+		if err := k.repo.CreateJWTKey(key); err != nil {
+			return err
+		}
+		stored = append([]models.JWTKey{*key}, stored...)
+	}
+
+	keys := make([]signingKey, 0, len(stored))
+	for _, s := range stored {
+		der, err := k.open(s)
+		if err != nil {
+			log.Printf("skipping jwt key %s: %v", s.ID, err)
+			continue
+		}
+		if s.KEKID == "" {
+			if err := k.repo.SealJWTKey(s.ID, k.seal(s.ID, s.Algorithm, der), k.kekID); err != nil {
+				log.Printf("sealing jwt key %s: %v", s.ID, err)
+			}
+		}
+
+		s.PrivateKey = der
+		key, err := parseSigningKey(s)
+		if err != nil {
+			log.Printf("skipping jwt key %s: %v", s.ID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable jwt signing keys")
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+
+	return k.repo.PurgeJWTKeys()
+}
+
+func (k *JWTKeyRing) generate() (*models.JWTKey, error) {
+	var private any
+	var err error
+
+	switch k.cfg.Algorithm {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &models.JWTKey{
+		ID:        uuid.NewString(),
+		Algorithm: k.cfg.Algorithm,
+		KEKID:     k.kekID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(JWKSMaxAge + k.cfg.RotateEvery + k.cfg.Overlap),
+	}
+	key.PrivateKey = k.seal(key.ID, key.Algorithm, der)
+	return key, nil
+}
+
+// jwtKeyAAD binds a sealed private key to its row, so ciphertexts can't be
+// swapped between kids or algorithms.
+func jwtKeyAAD(kid, alg string) []byte {
+	return []byte("ticketapp-jwt-key\n" + kid + "\n" + alg)
+}
+
+// seal encrypts a PKCS#8 private key as nonce || ciphertext.
+func (k *JWTKeyRing) seal(kid, alg string, der []byte) []byte {
+	nonce := make([]byte, k.kek.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return k.kek.Seal(nonce, nonce, der, jwtKeyAAD(kid, alg))
+}
+
+// open returns the PKCS#8 private key of a stored key. Legacy plaintext rows
+// are returned as they are.
+func (k *JWTKeyRing) open(s models.JWTKey) ([]byte, error) {
+	if s.KEKID == "" {
+		return s.PrivateKey, nil
+	}
+	if s.KEKID != k.kekID {
+		return nil, fmt.Errorf("sealed with unknown key-encryption key %s", s.KEKID)
+	}
+
+	n := k.kek.NonceSize()
+	if len(s.PrivateKey) < n {
+		return nil, errors.New("sealed key too short")
+	}
+	return k.kek.Open(nil, s.PrivateKey[:n], s.PrivateKey[n:], jwtKeyAAD(s.ID, s.Algorithm))
+}
+
+func parseSigningKey(s models.JWTKey) (signingKey, error) {
+	private, err := x509.ParsePKCS8PrivateKey(s.PrivateKey)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	key := signingKey{
+		kid:        s.ID,
+		private:    private,
+		activeFrom: s.CreatedAt.Add(JWKSMaxAge),
+	}
+
+	switch p := private.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.public = jwt.SigningMethodRS256, &p.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, p.Public()
+	default:
+		return signingKey{}, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	if key.method.Alg() != s.Algorithm {
+		return signingKey{}, fmt.Errorf("key is %s, stored as %s", key.method.Alg(), s.Algorithm)
+	}
+	return key, nil
+}
+
+// signer picks the newest key that has been published long enough. On a
+// fresh deployment nothing has been cached yet, so the newest key is used.
+func (k *JWTKeyRing) signer() signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range k.keys {
+		if !now.Before(key.activeFrom) {
+			return key
+		}
+	}
+	return k.keys[0]
+}
+
+func (k *JWTKeyRing) lookup(kid string) (signingKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.kid == kid {
+			return key, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public half of every key still accepted.
+func (k *JWTKeyRing) JWKS() []JWK {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := []JWK{}
+
+	for _, key := range k.keys {
+		jwk := JWK{Use: "sig", Alg: key.method.Alg(), Kid: key.kid}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			// shared secrets are never published
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+// StartJWTKeyRotation checks for due rotations and picks up keys added by
+// other replicas every PollInterval.
+func StartJWTKeyRotation(keys *JWTKeyRing) {
+	go func() {
+		for {
+			time.Sleep(keys.cfg.PollInterval)
+
+			if err := keys.Rotate(); err != nil {
+				log.Println("jwt key rotation:", err)
+			}
+		}
+	}()
+}
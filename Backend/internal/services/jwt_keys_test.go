@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"ticketapp/internal/models"
+)
+
+// memJWTKeyRepo keeps keys newest first, like the Postgres repo.
+type memJWTKeyRepo struct {
+	keys []models.JWTKey
+}
+
+func (r *memJWTKeyRepo) ListJWTKeys() ([]models.JWTKey, error) {
+	return append([]models.JWTKey(nil), r.keys...), nil
+}
+
+func (r *memJWTKeyRepo) CreateJWTKey(k *models.JWTKey) error {
+	r.keys = append([]models.JWTKey{*k}, r.keys...)
+	return nil
+}
+
+func (r *memJWTKeyRepo) SealJWTKey(kid string, privateKey []byte, kekID string) error {
+	for i := range r.keys {
+		if r.keys[i].ID == kid && r.keys[i].KEKID == "" {
+			r.keys[i].PrivateKey, r.keys[i].KEKID = privateKey, kekID
+		}
+	}
+	return nil
+}
+
+func (r *memJWTKeyRepo) PurgeJWTKeys() error { return nil }
+
+func testKEK(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestJWTKeyRingSealsPrivateKeys(t *testing.T) {
+	repo := &memJWTKeyRepo{}
+	ring, err := NewJWTKeyRing(repo, JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.keys) != 1 {
+		t.Fatalf("stored %d keys, want 1", len(repo.keys))
+	}
+	stored := repo.keys[0]
+	if stored.KEKID == "" {
+		t.Fatal("new key stored without a kek id")
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(stored.PrivateKey); err == nil {
+		t.Fatal("private key stored as plaintext PKCS#8")
+	}
+
+	// a second replica with the same KEK can use the key
+	other, err := NewJWTKeyRing(repo, JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := other.signer().kid, ring.signer().kid; got != want {
+		t.Errorf("second replica signs with %s, want %s", got, want)
+	}
+
+	// one with another KEK can't read it and refuses to start
+	if _, err := NewJWTKeyRing(repo, JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(2)}); err == nil {
+		t.Error("ring started with a KEK that opens none of the keys")
+	}
+	if len(repo.keys) != 1 {
+		t.Errorf("a wrong KEK must not add keys: %d keys", len(repo.keys))
+	}
+}
+
+func TestJWTKeyRingSealsLegacyKeys(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+
+	repo := &memJWTKeyRepo{keys: []models.JWTKey{{
+		ID:         "legacy",
+		Algorithm:  "EdDSA",
+		PrivateKey: der,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}}}
+
+	ring, err := NewJWTKeyRing(repo, JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ring.lookup("legacy"); !ok {
+		t.Error("legacy key no longer accepted")
+	}
+	if k := repo.keys[0]; k.KEKID == "" || bytes.Equal(k.PrivateKey, der) {
+		t.Error("legacy key left in plaintext")
+	}
+}
+
+func TestJWTKeyRingRejectsSwappedCiphertext(t *testing.T) {
+	repo := &memJWTKeyRepo{}
+	ring, err := NewJWTKeyRing(repo, JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := repo.keys[0]
+	k.ID = "other-kid"
+	if _, err := ring.open(k); err == nil {
+		t.Error("ciphertext opened under another kid")
+	}
+}
+
+func TestJWTKeyConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     JWTKeyConfig
+		overlap time.Duration
+		wantErr bool
+	}{
+		{"overlap gets poll slack", JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1)}, AccessTokenTTL + time.Minute, false},
+		{"custom poll interval", JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1), PollInterval: 2 * time.Minute}, AccessTokenTTL + 2*time.Minute, false},
+		{"longer overlap kept", JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1), Overlap: 24 * time.Hour}, 24 * time.Hour, false},
+		{"missing kek", JWTKeyConfig{Algorithm: "EdDSA"}, 0, true},
+		{"short kek", JWTKeyConfig{Algorithm: "EdDSA", KEK: []byte("short")}, 0, true},
+		{"poll slower than jwks cache", JWTKeyConfig{Algorithm: "EdDSA", KEK: testKEK(1), PollInterval: JWKSMaxAge}, 0, true},
+		{"unknown algorithm", JWTKeyConfig{Algorithm: "HS256", KEK: testKEK(1)}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewJWTKeyRing(&memJWTKeyRepo{}, tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ring.cfg.Overlap != tt.overlap {
+				t.Errorf("Overlap = %s, want %s", ring.cfg.Overlap, tt.overlap)
+			}
+		})
+	}
+}
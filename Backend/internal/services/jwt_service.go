@@ -2,57 +2,135 @@ package services
 
 import (
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// AccessTokenTTL is how long an access token is accepted after issue.
+const AccessTokenTTL = 15 * time.Minute
+
+// Authentication method references (RFC 8176) recorded in the amr claim.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRFederated   = "fed"
+	// AMRMultiFactor is added whenever more than one factor was used.
+	AMRMultiFactor = "mfa"
+)
+
+// AccessClaims is the payload of an access token.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+	// OrganizationID is the tenant the user belongs to, if any.
+	OrganizationID string `json:"org,omitempty"`
+	// SessionID is the refresh-token session the token was issued for.
+	SessionID string   `json:"sid,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+}
+
+// MFA reports whether the login behind the token satisfied a second factor.
+func (c *AccessClaims) MFA() bool {
+	return slices.Contains(c.AMR, AMRMultiFactor)
+}
+
+type JWTConfig struct {
+	Issuer string
+	// Audience lists the services tokens are meant for. Validate accepts a
+	// token when it names the first one.
+	Audience []string
+	// Leeway absorbs clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
 type JWTService struct {
-	secret string
+	keys *JWTKeyRing
+	cfg  JWTConfig
 }
 
-func NewJWTService(secret string) *JWTService {
-	return &JWTService{secret: secret}
+func NewJWTService(keys *JWTKeyRing, cfg JWTConfig) *JWTService {
+	if cfg.Issuer == "" {
+		cfg.Issuer = "ticketapp"
+	}
+	if len(cfg.Audience) == 0 {
+		cfg.Audience = []string{"ticketapp-api"}
+	}
+	return &JWTService{keys: keys, cfg: cfg}
 }
 
 // --------------------
 // ACCESS TOKEN CREATE
 // --------------------
-func (j *JWTService) GenerateAccessToken(userID, role string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":  userID,
-		"role": role,
-		"exp":  time.Now().Add(15 * time.Minute).Unix(),
-		"iat":  time.Now().Unix(),
-	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secret))
+// GenerateAccessToken signs claims after stamping the registered claims
+// (iss, aud, iat, nbf, exp, jti) itself.
+func (j *JWTService) GenerateAccessToken(claims AccessClaims) (string, error) {
+	now := time.Now()
+
+	claims.Issuer = j.cfg.Issuer
+	claims.Audience = j.cfg.Audience
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(AccessTokenTTL))
+	claims.ID = uuid.NewString()
+
+	key := j.keys.signer()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.kid != "" {
+		token.Header["kid"] = key.kid
+	}
+	return token.SignedString(key.private)
 }
 
 // --------------------
 // ACCESS TOKEN VERIFY
 // --------------------
-func (j *JWTService) Validate(tokenStr string) (jwt.MapClaims, error) {
+func (j *JWTService) Validate(tokenStr string) (*AccessClaims, error) {
 	if tokenStr == "" {
 		return nil, errors.New("missing token")
 	}
 
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(j.secret), nil
-	})
+	claims := &AccessClaims{}
+
+	token, err := jwt.ParseWithClaims(
+		tokenStr,
+		claims,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
 
+			key, ok := j.keys.lookup(kid)
+			if !ok {
+				return nil, errors.New("unknown signing key")
+			}
+			// the key, not the token, decides the algorithm
+			if t.Method.Alg() != key.method.Alg() {
+				return nil, errors.New("unexpected signing method")
+			}
+			return key.public, nil
+		},
+		jwt.WithIssuer(j.cfg.Issuer),
+		jwt.WithAudience(j.cfg.Audience[0]),
+		jwt.WithLeeway(j.cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
+	if claims.Subject == "" || claims.ID == "" || claims.IssuedAt == nil {
 		return nil, errors.New("invalid claims")
 	}
 
 	return claims, nil
 }
+
+// JWKS returns the public keys other services verify our tokens with.
+func (j *JWTService) JWKS() []JWK {
+	return j.keys.JWKS()
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+const (
+	// loginFreeFailures is how many wrong passwords an account gets before
+	// each further attempt has to wait.
+	loginFreeFailures = 2
+	loginDelayBase    = time.Second
+	loginDelayMax     = 30 * time.Second
+)
+
+type LoginGuardConfig struct {
+	// MaxAccountFailures locks an account; defaults to 5.
+	MaxAccountFailures int
+	// MaxIPFailures locks a client IP across all accounts; defaults to 50.
+	MaxIPFailures int
+	// Window is how long a failure counts; defaults to 15 minutes.
+	Window time.Duration
+	// Lockout is how long a lock lasts; defaults to 15 minutes.
+	Lockout time.Duration
+}
+
+// LoginGuard slows down and then locks out repeated failed password logins,
+// per account and per client IP. Accounts are tracked by the email that was
+// typed, whether or not it exists, so its answers never reveal which
+// addresses are registered.
+type LoginGuard struct {
+	repo repositories.LoginFailureRepository
+	cfg  LoginGuardConfig
+}
+
+func NewLoginGuard(repo repositories.LoginFailureRepository, cfg LoginGuardConfig) *LoginGuard {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 5
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 50
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.Lockout <= 0 {
+		cfg.Lockout = 15 * time.Minute
+	}
+
+	return &LoginGuard{repo: repo, cfg: cfg}
+}
+
+// MaxAccountFailures is how many wrong passwords lock an account.
+func (g *LoginGuard) MaxAccountFailures() int {
+	return g.cfg.MaxAccountFailures
+}
+
+// LoginKey normalizes an email for failure tracking.
+func LoginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check returns how long a login for email from ip has to wait, zero if it
+// may go ahead now.
+func (g *LoginGuard) Check(email, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, scope := range []struct{ name, key string }{
+		{models.LoginScopeAccount, LoginKey(email)},
+		{models.LoginScopeIP, ip},
+	} {
+		f, err := g.repo.GetLoginFailure(scope.name, scope.key)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if w := g.wait(f, now); w > wait {
+			wait = w
+		}
+	}
+
+	return wait, nil
+}
+
+func (g *LoginGuard) wait(f *models.LoginFailure, now time.Time) time.Duration {
+	if f.LockedUntil != nil {
+		if f.LockedUntil.After(now) {
+			return f.LockedUntil.Sub(now)
+		}
+		return 0
+	}
+
+	if f.Scope != models.LoginScopeAccount || f.Failures <= loginFreeFailures {
+		return 0
+	}
+	if now.Sub(f.LastFailedAt) > g.cfg.Window {
+		return 0
+	}
+
+	delay := loginDelayBase << (f.Failures - loginFreeFailures - 1)
+	if delay > loginDelayMax || delay <= 0 {
+		delay = loginDelayMax
+	}
+
+	return max(f.LastFailedAt.Add(delay).Sub(now), 0)
+}
+
+// Fail records a wrong password for email from ip. It returns the account's
+// lock expiry when this failure locked it, nil otherwise.
+func (g *LoginGuard) Fail(email, ip string) (*time.Time, error) {
+	now := time.Now()
+	since := now.Add(-g.cfg.Window)
+	until := now.Add(g.cfg.Lockout)
+
+	f, err := g.repo.RecordLoginFailure(models.LoginScopeIP, ip, now, since)
+	if err != nil {
+		return nil, err
+	}
+	if f.Failures == g.cfg.MaxIPFailures {
+		if err := g.repo.LockLogin(models.LoginScopeIP, ip, until); err != nil {
+			return nil, err
+		}
+	}
+
+	key := LoginKey(email)
+	f, err = g.repo.RecordLoginFailure(models.LoginScopeAccount, key, now, since)
+	if err != nil {
+		return nil, err
+	}
+	if f.Failures != g.cfg.MaxAccountFailures {
+		return nil, nil
+	}
+
+	if err := g.repo.LockLogin(models.LoginScopeAccount, key, until); err != nil {
+		return nil, err
+	}
+	return &until, nil
+}
+
+// Succeed forgets the account's failures. The IP's are kept, so one working
+// password doesn't reset a credential-stuffing run.
+func (g *LoginGuard) Succeed(email string) error {
+	err := g.repo.ClearLoginFailures(models.LoginScopeAccount, LoginKey(email))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Lockouts lists accounts and IPs that are locked right now.
+func (g *LoginGuard) Lockouts() ([]models.LoginFailure, error) {
+	return g.repo.ListLockedLogins(time.Now())
+}
+
+// Unlock clears a lock and its failure count; ErrNotFound if there was none.
+func (g *LoginGuard) Unlock(scope, key string) error {
+	if scope == models.LoginScopeAccount {
+		key = LoginKey(key)
+	}
+	return g.repo.ClearLoginFailures(scope, key)
+}
+
+// StartLoginFailureCleanup drops stale failure counts. Call once at startup.
+func StartLoginFailureCleanup(g *LoginGuard, interval time.Duration) {
+	go func() {
+		for {
+			if err := g.repo.PurgeLoginFailures(time.Now().Add(-g.cfg.Window)); err != nil {
+				log.Println("login failure cleanup:", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownSender    = errors.New("sender is not a known active user")
+	ErrUnverifiedSender = errors.New("sender could not be verified")
+	ErrUnknownTicket    = errors.New("reply address refers to a missing ticket")
+	ErrDuplicateMessage = errors.New("message already ingested")
+	ErrAutoReply        = errors.New("auto-submitted message ignored")
+	ErrEmptyMessage     = errors.New("message has no text")
+)
+
+var replyPrefixPattern = regexp.MustCompile(`(?i)^\s*((re|fw|fwd|aw|sv)\s*:\s*)+`)
+
+// IngestResult says what an inbound message turned into.
+type IngestResult struct {
+	TicketID    uuid.UUID  `json:"ticket_id"`
+	CommentID   *uuid.UUID `json:"comment_id,omitempty"`
+	Attachments int        `json:"attachments"`
+}
+
+// MailIngestService turns inbound email into tickets and comments.
+type MailIngestService struct {
+	userRepo       repositories.UserRepository
+	ticketRepo     repositories.TicketRepository
+	commentRepo    repositories.CommentRepository
+	attachmentRepo repositories.AttachmentRepository
+	inboundRepo    repositories.InboundMailRepository
+	tickets        *TicketService
+	store          storage.BlobStore
+	maxAttachment  int64
+	authservID     string
+	replies        *ReplyAddresses
+}
+
+func NewMailIngestService(
+	userRepo repositories.UserRepository,
+	ticketRepo repositories.TicketRepository,
+	commentRepo repositories.CommentRepository,
+	attachmentRepo repositories.AttachmentRepository,
+	inboundRepo repositories.InboundMailRepository,
+	tickets *TicketService,
+	store storage.BlobStore,
+	maxAttachment int64,
+	authservID string,
+	replies *ReplyAddresses,
+) *MailIngestService {
+	return &MailIngestService{
+		userRepo:       userRepo,
+		ticketRepo:     ticketRepo,
+		commentRepo:    commentRepo,
+		attachmentRepo: attachmentRepo,
+		inboundRepo:    inboundRepo,
+		tickets:        tickets,
+		store:          store,
+		maxAttachment:  maxAttachment,
+		authservID:     authservID,
+		replies:        replies,
+	}
+}
+
+// Ingest parses a raw message. The From header alone proves nothing, so the
+// sender must either pass SPF/DKIM/DMARC at our MTA (authservID) or write to
+// a reply address minted for them. A reply address adds a comment to its
+// ticket; otherwise a subject carrying a ticket reference the sender can see
+// adds a comment there and anything else opens a new ticket on their behalf.
+func (s *MailIngestService) Ingest(ctx context.Context, raw io.Reader) (*IngestResult, error) {
+	msg, err := ParseInboundMessage(raw, s.maxAttachment)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.AutoSubmitted {
+		return nil, ErrAutoReply
+	}
+
+	user, err := s.userRepo.GetByEmail(msg.From)
+	if err != nil || !user.IsActive {
+		return nil, ErrUnknownSender
+	}
+
+	var (
+		replyTicket uuid.UUID
+		viaReply    bool
+	)
+	if s.replies != nil {
+		replyTicket, viaReply = s.replies.Ticket(msg.Recipients, user.ID)
+	}
+	if !viaReply && !SenderAuthenticated(msg.AuthResults, s.authservID, msg.From) {
+		return nil, ErrUnverifiedSender
+	}
+
+	// claim the message id up front so concurrent deliveries can't both
+	// ingest it; a failed ingestion gives the claim back for a retry
+	if msg.MessageID != "" {
+		claimed, err := s.inboundRepo.Claim(msg.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			return nil, ErrDuplicateMessage
+		}
+	}
+
+	result, err := s.deliver(ctx, msg, user, replyTicket, viaReply)
+	if err != nil {
+		if msg.MessageID != "" {
+			if err := s.inboundRepo.Release(msg.MessageID); err != nil {
+				log.Println("release inbound message:", err)
+			}
+		}
+		return nil, err
+	}
+
+	if msg.MessageID != "" {
+		if err := s.inboundRepo.SetTicket(msg.MessageID, result.TicketID); err != nil {
+			log.Println("record inbound message:", err)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MailIngestService) deliver(
+	ctx context.Context,
+	msg *InboundMessage,
+	user *models.User,
+	replyTicket uuid.UUID,
+	viaReply bool,
+) (*IngestResult, error) {
+	tickets := s.ticketRepo
+	if scope := repositories.ScopeFor(user.Role, user.ID, user.OrganizationID); scope != (repositories.TicketScope{}) {
+		tickets = s.ticketRepo.Scoped(scope)
+	}
+
+	result := &IngestResult{}
+
+	var (
+		ticket *models.Ticket
+		err    error
+	)
+	if viaReply {
+		ticket, err = tickets.GetByID(replyTicket)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrUnknownTicket
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if id, ok := ParseTicketReference(msg.Subject); ok {
+		ticket, err = tickets.GetByID(id)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	if ticket != nil {
+		body := StripQuotedReply(msg.Text)
+		if body == "" && len(msg.Attachments) == 0 {
+			return nil, ErrEmptyMessage
+		}
+		if body == "" {
+			body = "(attachments only)"
+		}
+
+		comment := &models.TicketComment{
+			ID:       uuid.New(),
+			TicketID: ticket.ID,
+			AuthorID: user.ID,
+			Body:     body,
+		}
+		if err := s.commentRepo.Create(comment); err != nil {
+			return nil, err
+		}
+
+		if user.Role == models.RoleSupport || user.Role == models.RoleAdmin {
+			if err := tickets.MarkFirstResponse(ticket.ID); err != nil {
+				log.Println("mark first response:", err)
+			}
+		}
+
+		result.CommentID = &comment.ID
+	} else {
+		subject := strings.TrimSpace(replyPrefixPattern.ReplaceAllString(msg.Subject, ""))
+		subject = strings.TrimSpace(ticketRefPattern.ReplaceAllString(subject, ""))
+		if subject == "" {
+			subject = "(no subject)"
+		}
+
+		ticket = &models.Ticket{
+			ID:             uuid.New(),
+			Subject:        subject,
+			Description:    msg.Text,
+			Status:         models.TicketStatusOpen,
+			Priority:       models.TicketPriorityNormal,
+			RequesterID:    user.ID,
+			OrganizationID: user.OrganizationID,
+			CreatedAt:      time.Now(),
+		}
+		if err := s.tickets.Open(tickets, ticket); err != nil {
+			return nil, err
+		}
+	}
+
+	result.TicketID = ticket.ID
+
+	for _, a := range msg.Attachments {
+		if err := s.storeAttachment(ctx, ticket.ID, result.CommentID, user.ID, a); err != nil {
+			log.Printf("inbound mail %s: attachment %q: %v", msg.MessageID, a.Filename, err)
+			continue
+		}
+		result.Attachments++
+	}
+
+	return result, nil
+}
+
+func (s *MailIngestService) storeAttachment(
+	ctx context.Context,
+	ticketID uuid.UUID,
+	commentID *uuid.UUID,
+	uploaderID uuid.UUID,
+	a InboundAttachment,
+) error {
+	contentType, allowed := storage.SniffContentType(a.Data)
+	if !allowed {
+		return errors.New("file type not allowed")
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New(),
+		TicketID:    ticketID,
+		CommentID:   commentID,
+		UploaderID:  uploaderID,
+		Filename:    storage.SanitizeFilename(a.Filename),
+		ContentType: contentType,
+		Size:        int64(len(a.Data)),
+	}
+	attachment.StorageKey = "tickets/" + ticketID.String() + "/" + attachment.ID.String()
+
+	if err := s.store.Put(ctx, attachment.StorageKey, bytes.NewReader(a.Data), attachment.Size, contentType); err != nil {
+		return err
+	}
+
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		_ = s.store.Delete(ctx, attachment.StorageKey)
+		return err
+	}
+	return nil
+}
+
+// StartMaildirPoller ingests messages delivered to dir/new. Processed and
+// deliberately skipped messages move to dir/cur; anything that failed moves
+// to dir/failed for inspection. Call once at startup.
+func StartMaildirPoller(dir string, svc *MailIngestService, interval time.Duration) {
+	for _, sub := range []string{"new", "cur", "failed"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+			log.Println("maildir:", err)
+			return
+		}
+	}
+
+	go func() {
+		for {
+			entries, err := os.ReadDir(filepath.Join(dir, "new"))
+			if err != nil {
+				log.Println("maildir:", err)
+			}
+
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				ingestMaildirFile(dir, e.Name(), svc)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func ingestMaildirFile(dir, name string, svc *MailIngestService) {
+	path := filepath.Join(dir, "new", name)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println("maildir:", err)
+		return
+	}
+
+	_, err = svc.Ingest(context.Background(), f)
+	f.Close()
+
+	dest := filepath.Join(dir, "cur", name+":2,S")
+	switch {
+	case err == nil, errors.Is(err, ErrDuplicateMessage), errors.Is(err, ErrAutoReply):
+	default:
+		log.Printf("maildir: %s: %v", name, err)
+		dest = filepath.Join(dir, "failed", name)
+	}
+
+	if err := os.Rename(path, dest); err != nil {
+		log.Println("maildir:", err)
+	}
+}
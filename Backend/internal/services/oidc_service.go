@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/utils"
+)
+
+// oidcStateTTL bounds how long a user may spend at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCState means the callback's state is unknown, expired or reused.
+	ErrOIDCState = errors.New("invalid or expired sso state")
+	// ErrSSONotAllowed means the identity is valid but may not sign in here.
+	ErrSSONotAllowed = errors.New("sso login not allowed for this account")
+)
+
+type OIDCConfig struct {
+	// IssuerURL is used for discovery (/.well-known/openid-configuration).
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// ProvisionUsers creates a support user on first login when no local
+	// account has the IdP email.
+	ProvisionUsers bool
+	// AllowedDomains restricts provisioning to these email domains.
+	AllowedDomains []string
+	// HTTPClient talks to the IdP; nil means http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// OIDCIdentity is the verified result of an SSO login.
+type OIDCIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
+	// MFA is true when the IdP reports a multi-factor login (amr "mfa").
+	MFA bool
+}
+
+// OIDCService is the relying-party side of OpenID Connect single sign-on for
+// staff, using the authorization code flow with PKCE.
+type OIDCService struct {
+	cfg      OIDCConfig
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	repo     repositories.OIDCRepository
+	userRepo repositories.UserRepository
+}
+
+func NewOIDCService(
+	ctx context.Context,
+	cfg OIDCConfig,
+	repo repositories.OIDCRepository,
+	userRepo repositories.UserRepository,
+) (*OIDCService, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc issuer, client id and redirect url are required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	// emails are compared lower-cased
+	domains := make([]string, 0, len(cfg.AllowedDomains))
+	for _, d := range cfg.AllowedDomains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	if len(cfg.AllowedDomains) > 0 && len(domains) == 0 {
+		return nil, errors.New("oidc allowed domains are all blank")
+	}
+	cfg.AllowedDomains = domains
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, cfg.HTTPClient), cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	return &OIDCService{
+		cfg: cfg,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		repo:     repo,
+		userRepo: userRepo,
+	}, nil
+}
+
+// AuthCodeURL starts a login. The returned state must also be bound to the
+// browser (a cookie) and checked on the way back.
+func (s *OIDCService) AuthCodeURL() (state, url string, err error) {
+	state = uuid.NewString()
+	nonce := uuid.NewString()
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.repo.CreateLoginState(HashToken(state), &models.OIDCLoginState{
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	url = s.oauth.AuthCodeURL(
+		state,
+		oauth2.S256ChallengeOption(verifier),
+		oidc.Nonce(nonce),
+	)
+	return state, url, nil
+}
+
+// Exchange redeems the authorization code and verifies the ID token.
+func (s *OIDCService) Exchange(ctx context.Context, state, code string) (*OIDCIdentity, error) {
+	login, err := s.repo.TakeLoginState(HashToken(state))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.cfg.HTTPClient)
+
+	token, err := s.oauth.Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc code exchange: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	idToken, err := s.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id token: %w", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return nil, errors.New("oidc id token nonce mismatch")
+	}
+
+	var claims struct {
+		Email         string   `json:"email"`
+		EmailVerified *bool    `json:"email_verified"`
+		Name          string   `json:"name"`
+		AMR           []string `json:"amr"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// only an email the IdP vouches for may be matched against local
+	// accounts; a missing claim proves nothing
+	if claims.EmailVerified == nil || !*claims.EmailVerified {
+		claims.Email = ""
+	}
+
+	return &OIDCIdentity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   strings.ToLower(claims.Email),
+		Name:    claims.Name,
+		MFA:     slices.Contains(claims.AMR, AMRMultiFactor),
+	}, nil
+}
+
+// ResolveUser maps an identity onto a local staff account: by the linked
+// IdP subject first, then by email (linking it), and finally by provisioning
+// a support user when enabled. Admin accounts are never linked by email;
+// their identity has to be linked by an operator.
+func (s *OIDCService) ResolveUser(id *OIDCIdentity) (*models.User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ssoAllowed(user) {
+		return nil, ErrSSONotAllowed
+	}
+	return user, nil
+}
+
+// ssoAllowed reports whether the account may sign in through the IdP.
+func ssoAllowed(user *models.User) bool {
+	return user.IsActive && (user.Role == models.RoleSupport || user.Role == models.RoleAdmin)
+}
+
+func (s *OIDCService) findUser(id *OIDCIdentity) (*models.User, error) {
+	userID, err := s.repo.IdentityUser(id.Issuer, id.Subject)
+	if err == nil {
+		return s.userRepo.GetByID(userID)
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	if id.Email == "" {
+		return nil, ErrSSONotAllowed
+	}
+
+	user, err := s.userRepo.GetByEmail(id.Email)
+	if err != nil {
+		if !s.mayProvision(id.Email) {
+			return nil, ErrSSONotAllowed
+		}
+		if user, err = s.provision(id); err != nil {
+			return nil, err
+		}
+	}
+	// nothing is linked to an account that could not sign in anyway
+	if user.Role == models.RoleAdmin || !ssoAllowed(user) {
+		return nil, ErrSSONotAllowed
+	}
+
+	// the account is bound to another subject: the email was reassigned
+	if err := s.repo.LinkIdentity(user.ID, id.Issuer, id.Subject); err != nil {
+		if errors.Is(err, repositories.ErrConflict) {
+			return nil, ErrSSONotAllowed
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) mayProvision(email string) bool {
+	if !s.cfg.ProvisionUsers {
+		return false
+	}
+	if len(s.cfg.AllowedDomains) == 0 {
+		return true
+	}
+
+	_, domain, _ := strings.Cut(email, "@")
+	return slices.Contains(s.cfg.AllowedDomains, domain)
+}
+
+func (s *OIDCService) provision(id *OIDCIdentity) (*models.User, error) {
+	// random password nobody knows; these users sign in through the IdP
+	passwordHash, err := utils.HashPassword(uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+
+	username := id.Name
+	if username == "" {
+		username = id.Email
+	}
+
+	user := models.User{
+		ID:           uuid.New(),
+		Email:        id.Email,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         models.RoleSupport,
+		IsActive:     true,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
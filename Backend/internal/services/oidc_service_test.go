@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+const testClientID = "ticketapp"
+
+// fakeIdP is an OpenID provider with discovery, a JWKS and a token endpoint
+// that checks PKCE. Codes are registered by the test, standing in for the
+// authorization endpoint.
+type fakeIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant
+}
+
+type fakeGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key, grants: map[string]fakeGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.srv.URL,
+			"authorization_endpoint":                idp.srv.URL + "/authorize",
+			"token_endpoint":                        idp.srv.URL + "/token",
+			"jwks_uri":                              idp.srv.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   b64(key.N.Bytes()),
+			"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", idp.token)
+
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *fakeIdP) grant(code, challenge string, claims jwt.MapClaims) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.grants[code] = fakeGrant{challenge: challenge, claims: claims}
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	g, ok := idp.grants[r.FormValue("code")]
+	delete(idp.grants, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, g.claims)
+	tok.Header["kid"] = "test"
+	idToken, err := tok.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "at",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// memOIDCRepo is an in-memory repositories.OIDCRepository.
+type memOIDCRepo struct {
+	states     map[string]models.OIDCLoginState
+	identities map[[2]string]uuid.UUID
+}
+
+func newMemOIDCRepo() *memOIDCRepo {
+	return &memOIDCRepo{states: map[string]models.OIDCLoginState{}, identities: map[[2]string]uuid.UUID{}}
+}
+
+func (r *memOIDCRepo) CreateLoginState(stateHash string, s *models.OIDCLoginState) error {
+	r.states[stateHash] = *s
+	return nil
+}
+
+func (r *memOIDCRepo) TakeLoginState(stateHash string) (*models.OIDCLoginState, error) {
+	s, ok := r.states[stateHash]
+	delete(r.states, stateHash)
+	if !ok || time.Now().After(s.ExpiresAt) {
+		return nil, repositories.ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r *memOIDCRepo) IdentityUser(issuer, subject string) (uuid.UUID, error) {
+	id, ok := r.identities[[2]string{issuer, subject}]
+	if !ok {
+		return uuid.Nil, repositories.ErrNotFound
+	}
+	return id, nil
+}
+
+func (r *memOIDCRepo) LinkIdentity(userID uuid.UUID, issuer, subject string) error {
+	for k, id := range r.identities {
+		if id == userID && k[0] == issuer && k[1] != subject {
+			return repositories.ErrConflict
+		}
+	}
+	r.identities[[2]string{issuer, subject}] = userID
+	return nil
+}
+
+// memUserRepo covers the lookups SSO needs; anything else panics.
+type memUserRepo struct {
+	repositories.UserRepository
+	users []models.User
+}
+
+func (r *memUserRepo) GetByID(id uuid.UUID) (*models.User, error) {
+	for i := range r.users {
+		if r.users[i].ID == id {
+			return &r.users[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r *memUserRepo) GetByEmail(email string) (*models.User, error) {
+	for i := range r.users {
+		if r.users[i].Email == email {
+			return &r.users[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r *memUserRepo) Create(u models.User) error {
+	r.users = append(r.users, u)
+	return nil
+}
+
+func newTestOIDCService(t *testing.T, idp *fakeIdP, cfg OIDCConfig, users *memUserRepo) (*OIDCService, *memOIDCRepo) {
+	t.Helper()
+
+	cfg.IssuerURL = idp.srv.URL
+	cfg.ClientID = testClientID
+	cfg.RedirectURL = "https://app.example.com/auth/oidc/callback"
+	cfg.HTTPClient = idp.srv.Client()
+
+	repo := newMemOIDCRepo()
+	s, err := NewOIDCService(context.Background(), cfg, repo, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, repo
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newFakeIdP(t)
+	s, _ := newTestOIDCService(t, idp, OIDCConfig{}, &memUserRepo{})
+
+	tests := []struct {
+		name string
+		// edit changes what the IdP will answer for this login
+		edit func(challenge *string, claims jwt.MapClaims)
+		// state replaces the callback's state when set
+		state     string
+		replay    bool
+		wantErr   error
+		wantAnyEr bool
+		wantEmail string
+		wantMFA   bool
+	}{
+		{name: "verified email", wantEmail: "jane@example.com"},
+		{
+			name:      "idp reports mfa",
+			edit:      func(_ *string, c jwt.MapClaims) { c["amr"] = []string{"pwd", "mfa"} },
+			wantEmail: "jane@example.com",
+			wantMFA:   true,
+		},
+		{
+			name: "email_verified missing",
+			edit: func(_ *string, c jwt.MapClaims) { delete(c, "email_verified") },
+		},
+		{
+			name: "email_verified false",
+			edit: func(_ *string, c jwt.MapClaims) { c["email_verified"] = false },
+		},
+		{name: "unknown state", state: "not-a-state", wantErr: ErrOIDCState},
+		{name: "replayed state", replay: true, wantErr: ErrOIDCState},
+		{
+			name:      "nonce mismatch",
+			edit:      func(_ *string, c jwt.MapClaims) { c["nonce"] = "someone-elses-nonce" },
+			wantAnyEr: true,
+		},
+		{
+			name:      "pkce mismatch",
+			edit:      func(ch *string, _ jwt.MapClaims) { *ch = "attacker-challenge" },
+			wantAnyEr: true,
+		},
+		{
+			name:      "token for another client",
+			edit:      func(_ *string, c jwt.MapClaims) { c["aud"] = "other-client" },
+			wantAnyEr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, authURL, err := s.AuthCodeURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := u.Query()
+			if q.Get("code_challenge_method") != "S256" || q.Get("state") != state {
+				t.Fatalf("auth url = %s", authURL)
+			}
+
+			challenge := q.Get("code_challenge")
+			claims := jwt.MapClaims{
+				"iss":            idp.srv.URL,
+				"aud":            testClientID,
+				"sub":            "subject-1",
+				"iat":            time.Now().Unix(),
+				"exp":            time.Now().Add(time.Minute).Unix(),
+				"nonce":          q.Get("nonce"),
+				"email":          "Jane@Example.com",
+				"email_verified": true,
+				"name":           "Jane",
+			}
+			if tt.edit != nil {
+				tt.edit(&challenge, claims)
+			}
+			code := "code-" + string(rune('a'+i))
+			idp.grant(code, challenge, claims)
+
+			if tt.state != "" {
+				state = tt.state
+			}
+			if tt.replay {
+				if _, err := s.Exchange(context.Background(), state, code); err != nil {
+					t.Fatal(err)
+				}
+				idp.grant(code, challenge, claims)
+			}
+
+			id, err := s.Exchange(context.Background(), state, code)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyEr:
+				if err == nil {
+					t.Fatalf("identity %+v accepted, want an error", id)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if id.Issuer != idp.srv.URL || id.Subject != "subject-1" {
+				t.Errorf("identity = %s / %s", id.Issuer, id.Subject)
+			}
+			if id.Email != tt.wantEmail || id.MFA != tt.wantMFA {
+				t.Errorf("email, mfa = %q, %v; want %q, %v", id.Email, id.MFA, tt.wantEmail, tt.wantMFA)
+			}
+		})
+	}
+}
+
+func TestOIDCResolveUser(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	support := models.User{ID: uuid.New(), Email: "agent@example.com", Role: models.RoleSupport, IsActive: true}
+	admin := models.User{ID: uuid.New(), Email: "boss@example.com", Role: models.RoleAdmin, IsActive: true}
+	linkedAdmin := models.User{ID: uuid.New(), Email: "root@example.com", Role: models.RoleAdmin, IsActive: true}
+	customer := models.User{ID: uuid.New(), Email: "client@example.com", Role: models.RoleCustomer, IsActive: true}
+	disabled := models.User{ID: uuid.New(), Email: "gone@example.com", Role: models.RoleSupport}
+	bound := models.User{ID: uuid.New(), Email: "bound@example.com", Role: models.RoleSupport, IsActive: true}
+
+	tests := []struct {
+		name      string
+		provision bool
+		domains   []string
+		subject   string
+		email     string
+		want      *models.User // nil: ErrSSONotAllowed
+		wantNew   bool
+	}{
+		{name: "linked admin by subject", subject: "sub-root", want: &linkedAdmin},
+		{name: "support linked by email", subject: "sub-agent", email: support.Email, want: &support},
+		{name: "admin is not linked by email", subject: "sub-boss", email: admin.Email},
+		{name: "customer", subject: "sub-client", email: customer.Email},
+		{name: "disabled", subject: "sub-gone", email: disabled.Email},
+		{name: "no verified email", subject: "sub-anon"},
+		{name: "email bound to another subject", subject: "sub-intruder", email: bound.Email},
+		{name: "unknown email without provisioning", subject: "sub-new", email: "new@example.com"},
+		{name: "provisioned in any domain", provision: true, subject: "sub-new", email: "new@anywhere.org", wantNew: true},
+		{name: "provisioned in allowed domain", provision: true, domains: []string{" Example.com "}, subject: "sub-new", email: "new@example.com", wantNew: true},
+		{name: "other domain", provision: true, domains: []string{"example.com"}, subject: "sub-new", email: "new@example.org"},
+		{name: "subdomain", provision: true, domains: []string{"example.com"}, subject: "sub-new", email: "new@evil.example.com"},
+		{name: "lookalike domain", provision: true, domains: []string{"example.com"}, subject: "sub-new", email: "new@example.com.evil.org"},
+		{name: "second at sign", provision: true, domains: []string{"example.com"}, subject: "sub-new", email: "new@evil.org@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &memUserRepo{users: []models.User{support, admin, linkedAdmin, customer, disabled, bound}}
+			s, repo := newTestOIDCService(t, idp, OIDCConfig{ProvisionUsers: tt.provision, AllowedDomains: tt.domains}, users)
+			_ = repo.LinkIdentity(linkedAdmin.ID, idp.srv.URL, "sub-root")
+			_ = repo.LinkIdentity(bound.ID, idp.srv.URL, "sub-bound")
+
+			got, err := s.ResolveUser(&OIDCIdentity{Issuer: idp.srv.URL, Subject: tt.subject, Email: tt.email})
+
+			linked, linkErr := repo.IdentityUser(idp.srv.URL, tt.subject)
+			switch {
+			case tt.wantNew:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Email != tt.email || got.Role != models.RoleSupport || !got.IsActive {
+					t.Errorf("provisioned %+v", got)
+				}
+				if linkErr != nil || linked != got.ID {
+					t.Error("provisioned user not linked to the subject")
+				}
+			case tt.want == nil:
+				if !errors.Is(err, ErrSSONotAllowed) {
+					t.Fatalf("err = %v, want ErrSSONotAllowed", err)
+				}
+				if linkErr == nil {
+					t.Error("subject linked despite the refusal")
+				}
+				if len(users.users) != 6 {
+					t.Error("user provisioned despite the refusal")
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.ID != tt.want.ID {
+					t.Errorf("resolved %s, want %s", got.Email, tt.want.Email)
+				}
+				if linked != tt.want.ID {
+					t.Error("subject not linked")
+				}
+			}
+		})
+	}
+}
+
+func TestNewOIDCServiceRejectsBlankDomains(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	_, err := NewOIDCService(context.Background(), OIDCConfig{
+		IssuerURL:      idp.srv.URL,
+		ClientID:       testClientID,
+		RedirectURL:    "https://app.example.com/auth/oidc/callback",
+		HTTPClient:     idp.srv.Client(),
+		ProvisionUsers: true,
+		AllowedDomains: strings.Split(" ,", ","),
+	}, newMemOIDCRepo(), &memUserRepo{})
+	if err == nil {
+		t.Error("blank allowed domains accepted; they would allow every domain")
+	}
+}
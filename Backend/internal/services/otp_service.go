@@ -1,20 +1,82 @@
 package services
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
 	"github.com/pquerna/otp/totp"
-	
 )
 
-type OTPService struct{}
+// recoveryCodeCount is how many one-time recovery codes a user gets.
+const recoveryCodeCount = 10
+
+// OTPConfig controls how TOTP codes are generated and checked. Changing
+// Period, Digits or Algorithm invalidates existing authenticator enrollments,
+// so they are meant to be fixed per deployment.
+type OTPConfig struct {
+	Issuer string
+	// Period is the length of one time-step.
+	Period time.Duration
+	Digits otp.Digits
+	// Algorithm is the HMAC hash: "SHA1", "SHA256" or "SHA512".
+	Algorithm string
+	// Skew is how many time-steps either side of now are still accepted.
+	Skew uint
+}
+
+type OTPService struct {
+	cfg       OTPConfig
+	algorithm otp.Algorithm
+}
+
+func NewOTPService(cfg OTPConfig) (*OTPService, error) {
+	if cfg.Issuer == "" {
+		cfg.Issuer = "RBAC-Auth"
+	}
+	if cfg.Period == 0 {
+		cfg.Period = 30 * time.Second
+	}
+	if cfg.Period < time.Second {
+		return nil, errors.New("otp period must be at least one second")
+	}
+	if cfg.Digits == 0 {
+		cfg.Digits = otp.DigitsSix
+	}
+	if cfg.Digits != otp.DigitsSix && cfg.Digits != otp.DigitsEight {
+		return nil, fmt.Errorf("unsupported otp digits %d", cfg.Digits)
+	}
+
+	var alg otp.Algorithm
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "SHA1":
+		alg = otp.AlgorithmSHA1
+	case "SHA256":
+		alg = otp.AlgorithmSHA256
+	case "SHA512":
+		alg = otp.AlgorithmSHA512
+	default:
+		return nil, fmt.Errorf("unsupported otp algorithm %q", cfg.Algorithm)
+	}
 
-func NewOTPService() *OTPService {
-	return &OTPService{}
+	return &OTPService{cfg: cfg, algorithm: alg}, nil
 }
 
 func (o *OTPService) GenerateSecret(email string) (string, string, error) {
 	key, err := totp.Generate(totp.GenerateOpts{
-		Issuer:      "RBAC-Auth",
+		Issuer:      o.cfg.Issuer,
 		AccountName: email,
+		Period:      uint(o.cfg.Period / time.Second),
+		Digits:      o.cfg.Digits,
+		Algorithm:   o.algorithm,
 	})
 	if err != nil {
 		return "", "", err
@@ -22,6 +84,80 @@ func (o *OTPService) GenerateSecret(email string) (string, string, error) {
 	return key.Secret(), key.URL(), nil
 }
 
-func (o *OTPService) Verify(secret, code string) bool {
-	return totp.Validate(code, secret)
+// Verify checks code against secret and returns the time-step it matched.
+// Callers persist the step and reject codes at or before it, which is what
+// stops a code being replayed inside its validity window.
+func (o *OTPService) Verify(secret, code string) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != o.cfg.Digits.Length() {
+		return 0, false
+	}
+
+	now := time.Now().Unix() / int64(o.cfg.Period/time.Second)
+	skew := int64(o.cfg.Skew)
+
+	for step := now - skew; step <= now+skew; step++ {
+		if step < 0 {
+			continue
+		}
+
+		want, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+			Digits:    o.cfg.Digits,
+			Algorithm: o.algorithm,
+		})
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// QRCodePNG renders an otpauth:// URL as a PNG for authenticator apps.
+func (o *OTPService) QRCodePNG(otpauthURL string) ([]byte, error) {
+	key, err := otp.NewKeyFromURL(otpauthURL)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateRecoveryCodes returns fresh one-time codes formatted xxxxx-xxxxx.
+func (o *OTPService) GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode canonicalises user input before hashing, so
+// spacing, dashes and case don't matter.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) == 10 {
+		return code[:5] + "-" + code[5:]
+	}
+	return code
 }
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// replyTokenMACSize is the truncated HMAC length. With the 16-byte ticket id
+// the token stays well inside the 64-character local-part limit.
+const replyTokenMACSize = 10
+
+var replyTokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ReplyAddresses mints and checks per-ticket reply addresses of the form
+// local+token@domain. The token binds a ticket to the notified user with an
+// HMAC, so a reply sent to it can be trusted as theirs even when their mail
+// domain publishes neither SPF nor DKIM.
+type ReplyAddresses struct {
+	local  string
+	domain string
+	secret []byte
+}
+
+// NewReplyAddresses takes the inbound mailbox address, e.g.
+// support@example.com, and a secret of at least 32 bytes.
+func NewReplyAddresses(address, secret string) (*ReplyAddresses, error) {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reply address: %w", err)
+	}
+	local, domain, _ := strings.Cut(strings.ToLower(addr.Address), "@")
+	if strings.Contains(local, "+") {
+		return nil, errors.New("reply address must not contain a + tag")
+	}
+	if len(secret) < 32 {
+		return nil, errors.New("reply address secret must be at least 32 bytes")
+	}
+
+	return &ReplyAddresses{local: local, domain: domain, secret: []byte(secret)}, nil
+}
+
+func (a *ReplyAddresses) mac(ticketID, userID uuid.UUID) []byte {
+	m := hmac.New(sha256.New, a.secret)
+	m.Write(ticketID[:])
+	m.Write(userID[:])
+	return m.Sum(nil)[:replyTokenMACSize]
+}
+
+// For returns the address userID should reply to about ticketID.
+func (a *ReplyAddresses) For(ticketID, userID uuid.UUID) string {
+	token := replyTokenEncoding.EncodeToString(append(ticketID[:], a.mac(ticketID, userID)...))
+	return a.local + "+" + strings.ToLower(token) + "@" + a.domain
+}
+
+// Ticket finds a reply address minted for userID among recipients and
+// returns the ticket it was minted for.
+func (a *ReplyAddresses) Ticket(recipients []string, userID uuid.UUID) (uuid.UUID, bool) {
+	for _, r := range recipients {
+		local, domain, ok := strings.Cut(strings.ToLower(r), "@")
+		if !ok || domain != a.domain {
+			continue
+		}
+		token, ok := strings.CutPrefix(local, a.local+"+")
+		if !ok {
+			continue
+		}
+
+		raw, err := replyTokenEncoding.DecodeString(strings.ToUpper(token))
+		if err != nil || len(raw) != len(uuid.Nil)+replyTokenMACSize {
+			continue
+		}
+		ticketID, _ := uuid.FromBytes(raw[:len(uuid.Nil)])
+		if hmac.Equal(raw[len(uuid.Nil):], a.mac(ticketID, userID)) {
+			return ticketID, true
+		}
+	}
+	return uuid.Nil, false
+}
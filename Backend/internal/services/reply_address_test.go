@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReplyAddresses(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+	a, err := NewReplyAddresses("Support <support@example.com>", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket, user, other := uuid.New(), uuid.New(), uuid.New()
+	addr := a.For(ticket, user)
+
+	if local, _, _ := strings.Cut(addr, "@"); len(local) > 64 {
+		t.Fatalf("local part %q is longer than 64 characters", local)
+	}
+
+	otherKey, _ := NewReplyAddresses("support@example.com", strings.Repeat("x", 32))
+	forged := otherKey.For(ticket, user)
+	token := strings.TrimSuffix(strings.TrimPrefix(addr, "support+"), "@example.com")
+	tampered := "a" + token[1:]
+	if token[0] == 'a' {
+		tampered = "b" + token[1:]
+	}
+
+	tests := []struct {
+		name       string
+		recipients []string
+		user       uuid.UUID
+		want       bool
+	}{
+		{"minted address", []string{addr}, user, true},
+		{"among other recipients", []string{"someone@example.com", addr}, user, true},
+		{"upper-cased by an MTA", []string{strings.ToUpper(addr)}, user, true},
+		{"another user", []string{addr}, other, false},
+		{"other secret", []string{forged}, user, false},
+		{"other domain", []string{"support+" + token + "@evil.test"}, user, false},
+		{"other mailbox", []string{"sales+" + token + "@example.com"}, user, false},
+		{"tampered token", []string{"support+" + tampered + "@example.com"}, user, false},
+		{"plain mailbox", []string{"support@example.com"}, user, false},
+		{"none", nil, user, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := a.Ticket(tt.recipients, tt.user)
+			if ok != tt.want || (ok && got != ticket) {
+				t.Errorf("Ticket(%q) = %v, %v; want %v", tt.recipients, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReplyAddressesRejects(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+	for _, tt := range []struct{ addr, secret string }{
+		{"not an address", secret},
+		{"support+tag@example.com", secret},
+		{"support@example.com", "short"},
+	} {
+		if _, err := NewReplyAddresses(tt.addr, tt.secret); err == nil {
+			t.Errorf("NewReplyAddresses(%q, %d bytes) = nil error", tt.addr, len(tt.secret))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// SLAService stamps deadlines on tickets and reports their SLA state.
+type SLAService struct {
+	// AtRiskFraction is how much of a deadline's window may remain before
+	// the ticket is reported at risk, e.g. 0.25 = last quarter.
+	AtRiskFraction float64
+}
+
+func NewSLAService() *SLAService {
+	return &SLAService{AtRiskFraction: 0.25}
+}
+
+// Stamp sets the ticket's deadlines from its creation time, the policy
+// targets and any time already spent paused.
+func (s *SLAService) Stamp(t *models.Ticket, policy *models.SLAPolicy) {
+	paused := time.Duration(t.SLAPausedSeconds) * time.Second
+
+	if t.FirstRespondedAt == nil {
+		due := t.CreatedAt.Add(time.Duration(policy.FirstResponseMinutes)*time.Minute + paused)
+		t.FirstResponseDue = &due
+	}
+
+	due := t.CreatedAt.Add(time.Duration(policy.ResolutionMinutes)*time.Minute + paused)
+	t.ResolutionDue = &due
+}
+
+// Status computes the ticket's SLA state at now.
+func (s *SLAService) Status(t *models.Ticket, now time.Time) *models.SLAStatus {
+	if t.FirstResponseDue == nil && t.ResolutionDue == nil {
+		return nil
+	}
+
+	return &models.SLAStatus{
+		FirstResponse: s.state(t, t.FirstResponseDue, t.FirstRespondedAt, t.FirstResponseBreached, now),
+		Resolution:    s.state(t, t.ResolutionDue, t.ResolvedAt, t.ResolutionBreached, now),
+	}
+}
+
+func (s *SLAService) state(
+	t *models.Ticket,
+	due, doneAt *time.Time,
+	flagged bool,
+	now time.Time,
+) string {
+	switch {
+	case due == nil:
+		return ""
+	case doneAt != nil:
+		if flagged || doneAt.After(*due) {
+			return models.SLAStateBreached
+		}
+		return models.SLAStateMet
+	case flagged:
+		return models.SLAStateBreached
+	case t.SLAPausedAt != nil:
+		return models.SLAStatePaused
+	case now.After(*due):
+		return models.SLAStateBreached
+	}
+
+	window := due.Sub(t.CreatedAt)
+	if due.Sub(now) <= time.Duration(float64(window)*s.AtRiskFraction) {
+		return models.SLAStateAtRisk
+	}
+	return models.SLAStateOnTrack
+}
+
+// StartSLAEvaluator periodically flags tickets that breached their SLA.
+// Call once at startup.
+func StartSLAEvaluator(ticketRepo repositories.TicketRepository, interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			ids, err := ticketRepo.FlagSLABreaches(time.Now())
+			if err != nil {
+				log.Println("sla evaluator:", err)
+				continue
+			}
+			for _, id := range ids {
+				log.Printf("SLA breached: ticket=%s", id)
+			}
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// TicketService holds the rules for opening tickets shared by the HTTP API
+// and inbound mail.
+type TicketService struct {
+	slaRepo  repositories.SLAPolicyRepository
+	sla      *SLAService
+	assigner *AssignmentService // nil disables auto-assignment
+}
+
+func NewTicketService(
+	slaRepo repositories.SLAPolicyRepository,
+	sla *SLAService,
+	assigner *AssignmentService,
+) *TicketService {
+	return &TicketService{
+		slaRepo:  slaRepo,
+		sla:      sla,
+		assigner: assigner,
+	}
+}
+
+// StampSLA sets the ticket's deadlines from the matching policy. Tickets
+// without a policy simply carry no deadlines.
+func (s *TicketService) StampSLA(ticket *models.Ticket) error {
+	policy, err := s.slaRepo.Resolve(ticket.OrganizationID, ticket.Priority)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.sla.Stamp(ticket, policy)
+	return nil
+}
+
+// Open stamps SLA deadlines, auto-assigns and stores a new ticket through
+// ticketRepo, which should already be scoped to the caller.
+func (s *TicketService) Open(ticketRepo repositories.TicketRepository, ticket *models.Ticket) error {
+	if err := s.StampSLA(ticket); err != nil {
+		return err
+	}
+
+	if s.assigner != nil {
+		if err := s.assigner.Assign(ticket); err != nil {
+			log.Println("auto-assign:", err)
+		}
+	}
+
+	return ticketRepo.Create(ticket)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+
+	"ticketapp/internal/models"
+)
+
+var (
+	ErrInvalidTransition   = errors.New("invalid status transition")
+	ErrTransitionForbidden = errors.New("transition not allowed for this role")
+)
+
+// who may perform a transition
+type transitionActor int
+
+const (
+	actorStaff transitionActor = iota
+	actorStaffOrRequester
+)
+
+// ticketTransitions is the status state machine: from -> to -> who may move it.
+// Reopen is resolved/closed -> open.
+var ticketTransitions = map[string]map[string]transitionActor{
+	models.TicketStatusOpen: {
+		models.TicketStatusTriaged:    actorStaff,
+		models.TicketStatusInProgress: actorStaff,
+	},
+	models.TicketStatusTriaged: {
+		models.TicketStatusInProgress: actorStaff,
+	},
+	models.TicketStatusInProgress: {
+		models.TicketStatusWaitingOnCustomer: actorStaff,
+		models.TicketStatusResolved:          actorStaff,
+	},
+	models.TicketStatusWaitingOnCustomer: {
+		models.TicketStatusInProgress: actorStaffOrRequester,
+		models.TicketStatusResolved:   actorStaff,
+	},
+	models.TicketStatusResolved: {
+		models.TicketStatusClosed: actorStaffOrRequester,
+		models.TicketStatusOpen:   actorStaffOrRequester,
+	},
+	models.TicketStatusClosed: {
+		models.TicketStatusOpen: actorStaffOrRequester,
+	},
+}
+
+// TicketWorkflow enforces the ticket status state machine.
+type TicketWorkflow struct{}
+
+func NewTicketWorkflow() *TicketWorkflow {
+	return &TicketWorkflow{}
+}
+
+// Check reports whether a caller with role may move a ticket from one status
+// to another. isRequester is true when the caller opened the ticket.
+func (w *TicketWorkflow) Check(from, to, role string, isRequester bool) error {
+	actor, ok := ticketTransitions[from][to]
+	if !ok {
+		return ErrInvalidTransition
+	}
+
+	staff := role == models.RoleSupport || role == models.RoleAdmin
+
+	switch actor {
+	case actorStaff:
+		if !staff {
+			return ErrTransitionForbidden
+		}
+	case actorStaffOrRequester:
+		if !staff && !isRequester {
+			return ErrTransitionForbidden
+		}
+	}
+	return nil
+}
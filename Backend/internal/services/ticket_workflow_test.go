@@ -0,0 +1,53 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"ticketapp/internal/models"
+)
+
+func TestTicketWorkflowCheck(t *testing.T) {
+	const (
+		open     = models.TicketStatusOpen
+		triaged  = models.TicketStatusTriaged
+		progress = models.TicketStatusInProgress
+		waiting  = models.TicketStatusWaitingOnCustomer
+		resolved = models.TicketStatusResolved
+		closed   = models.TicketStatusClosed
+	)
+
+	tests := []struct {
+		name        string
+		from, to    string
+		role        string
+		isRequester bool
+		want        error
+	}{
+		{"support triages", open, triaged, models.RoleSupport, false, nil},
+		{"admin starts work", open, progress, models.RoleAdmin, false, nil},
+		{"customer cannot triage own ticket", open, triaged, models.RoleCustomer, true, ErrTransitionForbidden},
+		{"support waits on customer", progress, waiting, models.RoleSupport, false, nil},
+		{"requester answers", waiting, progress, models.RoleCustomer, true, nil},
+		{"other customer cannot answer", waiting, progress, models.RoleCustomer, false, ErrTransitionForbidden},
+		{"customer cannot resolve", waiting, resolved, models.RoleCustomer, true, ErrTransitionForbidden},
+		{"requester closes", resolved, closed, models.RoleCustomer, true, nil},
+		{"requester reopens resolved", resolved, open, models.RoleCustomer, true, nil},
+		{"support reopens closed", closed, open, models.RoleSupport, false, nil},
+		{"other customer cannot reopen", closed, open, models.RoleCustomer, false, ErrTransitionForbidden},
+		{"skip straight to resolved", open, resolved, models.RoleAdmin, false, ErrInvalidTransition},
+		{"closed to in progress", closed, progress, models.RoleSupport, false, ErrInvalidTransition},
+		{"same status", open, open, models.RoleSupport, false, ErrInvalidTransition},
+		{"unknown status", "archived", open, models.RoleAdmin, false, ErrInvalidTransition},
+	}
+
+	w := NewTicketWorkflow()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.Check(tt.from, tt.to, tt.role, tt.isRequester)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Check(%s -> %s, %s) = %v, want %v", tt.from, tt.to, tt.role, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// TokenDenylist records access tokens revoked before they expire: one token
+// by its jti, every token of a session by its sid, or every token a user was
+// issued up to a point in time.
+// Entries only need to outlive the tokens they cover, so "until" is the
+// latest expiry of those tokens and Purge drops anything past it.
+type TokenDenylist interface {
+	RevokeToken(jti string, until time.Time) error
+	// RevokeSession rejects every token issued for sessionID.
+	RevokeSession(sessionID string, until time.Time) error
+	// RevokeUser rejects every token for userID issued at or before before.
+	RevokeUser(userID string, before, until time.Time) error
+	// IsRevoked checks a token; sessionID is empty for tokens without one.
+	IsRevoked(jti, userID, sessionID string, issuedAt time.Time) (bool, error)
+	Purge() error
+}
+
+// MemoryTokenDenylist is a single-process TokenDenylist. Revocations are lost
+// on restart and not shared between replicas; use the Postgres one for that.
+type MemoryTokenDenylist struct {
+	mu       sync.Mutex
+	jtis     map[string]time.Time
+	sessions map[string]time.Time
+	users    map[string]userCutoff
+}
+
+type userCutoff struct {
+	before time.Time
+	until  time.Time
+}
+
+func NewMemoryTokenDenylist() *MemoryTokenDenylist {
+	return &MemoryTokenDenylist{
+		jtis:     make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[string]userCutoff),
+	}
+}
+
+func (d *MemoryTokenDenylist) RevokeToken(jti string, until time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.jtis[jti] = until
+	return nil
+}
+
+func (d *MemoryTokenDenylist) RevokeSession(sessionID string, until time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sessions[sessionID] = until
+	return nil
+}
+
+func (d *MemoryTokenDenylist) RevokeUser(userID string, before, until time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if c, ok := d.users[userID]; ok && c.before.After(before) {
+		before = c.before
+	}
+	d.users[userID] = userCutoff{before: before, until: until}
+	return nil
+}
+
+func (d *MemoryTokenDenylist) IsRevoked(jti, userID, sessionID string, issuedAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.jtis[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if _, ok := d.sessions[sessionID]; ok && sessionID != "" {
+		return true, nil
+	}
+
+	if c, ok := d.users[userID]; ok && !issuedAt.After(c.before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *MemoryTokenDenylist) Purge() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for jti, until := range d.jtis {
+		if now.After(until) {
+			delete(d.jtis, jti)
+		}
+	}
+	for sid, until := range d.sessions {
+		if now.After(until) {
+			delete(d.sessions, sid)
+		}
+	}
+	for id, c := range d.users {
+		if now.After(c.until) {
+			delete(d.users, id)
+		}
+	}
+	return nil
+}
+
+// StartTokenDenylistCleanup periodically drops entries whose tokens have
+// expired anyway.
+func StartTokenDenylistCleanup(d TokenDenylist, interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			if err := d.Purge(); err != nil {
+				log.Println("token denylist cleanup:", err)
+			}
+		}
+	}()
+}
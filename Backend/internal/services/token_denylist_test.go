@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryTokenDenylist(t *testing.T) {
+	now := time.Now()
+	d := NewMemoryTokenDenylist()
+
+	_ = d.RevokeToken("jti-revoked", now.Add(time.Minute))
+	_ = d.RevokeSession("sid-revoked", now.Add(time.Minute))
+	_ = d.RevokeUser("user-cut", now, now.Add(time.Minute))
+
+	tests := []struct {
+		name           string
+		jti, user, sid string
+		issuedAt       time.Time
+		want           bool
+	}{
+		{"untouched token", "jti", "user", "sid", now, false},
+		{"revoked jti", "jti-revoked", "user", "sid", now, true},
+		{"revoked session", "jti", "user", "sid-revoked", now, true},
+		{"token without a session", "jti", "user", "", now, false},
+		{"issued before user cutoff", "jti", "user-cut", "sid", now.Add(-time.Second), true},
+		{"issued at user cutoff", "jti", "user-cut", "sid", now, true},
+		{"issued after user cutoff", "jti", "user-cut", "sid", now.Add(time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.IsRevoked(tt.jti, tt.user, tt.sid, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked(%q, %q, %q) = %v, want %v", tt.jti, tt.user, tt.sid, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryTokenDenylistPurge(t *testing.T) {
+	past := time.Now().Add(-time.Second)
+	d := NewMemoryTokenDenylist()
+
+	_ = d.RevokeToken("jti", past)
+	_ = d.RevokeSession("sid", past)
+	_ = d.RevokeUser("user", past, past)
+	if err := d.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.jtis)+len(d.sessions)+len(d.users) != 0 {
+		t.Errorf("expired entries left: %d jtis, %d sessions, %d users", len(d.jtis), len(d.sessions), len(d.users))
+	}
+}
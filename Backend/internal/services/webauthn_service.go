@@ -0,0 +1,379 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+)
+
+// ceremonyTTL bounds how long a browser has to answer a WebAuthn prompt.
+const ceremonyTTL = 5 * time.Minute
+
+var (
+	// ErrCeremonyInvalid means the ceremony token is unknown, expired or
+	// already used.
+	ErrCeremonyInvalid = errors.New("invalid or expired webauthn ceremony")
+	// ErrNoCredentials means the user has nothing registered to assert with.
+	ErrNoCredentials = errors.New("no webauthn credentials registered")
+	// ErrCloneWarning means the authenticator's signature counter did not
+	// advance, so the credential may have been cloned. The assertion is
+	// rejected and the stored counter left as it was.
+	ErrCloneWarning = errors.New("webauthn authenticator may be cloned")
+)
+
+type WebAuthnConfig struct {
+	// RPID is the relying party id, normally the site's registrable domain.
+	RPID          string
+	RPDisplayName string
+	// RPOrigins are the exact origins browsers may run ceremonies from.
+	RPOrigins []string
+}
+
+// WebAuthnService runs registration and assertion ceremonies for passkeys
+// and security keys. Ceremony state is kept server-side and referenced by
+// an opaque token, so it survives across replicas.
+type WebAuthnService struct {
+	wa       *webauthn.WebAuthn
+	repo     repositories.WebAuthnRepository
+	userRepo repositories.UserRepository
+}
+
+func NewWebAuthnService(
+	cfg WebAuthnConfig,
+	repo repositories.WebAuthnRepository,
+	userRepo repositories.UserRepository,
+) (*WebAuthnService, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnService{wa: wa, repo: repo, userRepo: userRepo}, nil
+}
+
+// HasCredentials reports whether the user can use WebAuthn as a second factor.
+func (s *WebAuthnService) HasCredentials(userID uuid.UUID) (bool, error) {
+	creds, err := s.repo.ListCredentials(userID)
+	return len(creds) > 0, err
+}
+
+func (s *WebAuthnService) ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.repo.ListCredentials(userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(userID, id uuid.UUID) error {
+	return s.repo.DeleteCredential(userID, id)
+}
+
+// BeginRegistration returns the ceremony token and the options to pass to
+// navigator.credentials.create.
+func (s *WebAuthnService) BeginRegistration(user *models.User) (string, *protocol.CredentialCreation, error) {
+	wu, err := s.loadUser(user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	creation, session, err := s.wa.BeginRegistration(
+		wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.creds).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := s.saveCeremony(models.WebAuthnCeremonyRegister, &user.ID, nil, session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, creation, nil
+}
+
+// FinishRegistration verifies the attestation in r's body and stores the
+// new credential under name.
+func (s *WebAuthnService) FinishRegistration(
+	user *models.User,
+	token, name string,
+	r *http.Request,
+) (*models.WebAuthnCredential, error) {
+	ceremony, session, err := s.takeCeremony(token, models.WebAuthnCeremonyRegister)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID == nil || *ceremony.UserID != user.ID {
+		return nil, ErrCeremonyInvalid
+	}
+
+	wu, err := s.loadUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.wa.FinishRegistration(wu, *session, r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &models.WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: cred.ID,
+		Name:         name,
+		Data:         data,
+	}
+	if err := s.repo.CreateCredential(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// BeginMFALogin starts an assertion for a user who has passed the password
+// step; mfaChallengeID ties the ceremony to that login attempt.
+func (s *WebAuthnService) BeginMFALogin(
+	user *models.User,
+	mfaChallengeID uuid.UUID,
+) (string, *protocol.CredentialAssertion, error) {
+	wu, err := s.loadUser(user)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(wu.creds) == 0 {
+		return "", nil, ErrNoCredentials
+	}
+
+	assertion, session, err := s.wa.BeginLogin(wu)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := s.saveCeremony(models.WebAuthnCeremonyMFA, &user.ID, &mfaChallengeID, session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, assertion, nil
+}
+
+// FinishMFALogin verifies the assertion and returns the user and the MFA
+// challenge it was started for. On ErrCloneWarning the user is returned too,
+// so the caller can record it.
+func (s *WebAuthnService) FinishMFALogin(token string, r *http.Request) (*models.User, uuid.UUID, error) {
+	ceremony, session, err := s.takeCeremony(token, models.WebAuthnCeremonyMFA)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if ceremony.UserID == nil || ceremony.MFAChallengeID == nil {
+		return nil, uuid.Nil, ErrCeremonyInvalid
+	}
+
+	user, err := s.userRepo.GetByID(*ceremony.UserID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	wu, err := s.loadUser(user)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	cred, err := s.wa.FinishLogin(wu, *session, r)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if cred.Authenticator.CloneWarning {
+		return user, uuid.Nil, ErrCloneWarning
+	}
+
+	if err := s.touch(cred); err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return user, *ceremony.MFAChallengeID, nil
+}
+
+// BeginPasskeyLogin starts a passwordless login; the browser picks any
+// discoverable credential for this site.
+func (s *WebAuthnService) BeginPasskeyLogin() (string, *protocol.CredentialAssertion, error) {
+	assertion, session, err := s.wa.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := s.saveCeremony(models.WebAuthnCeremonyPasskey, nil, nil, session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, assertion, nil
+}
+
+// FinishPasskeyLogin verifies a discoverable assertion and returns the user
+// its user handle belongs to, also alongside ErrCloneWarning.
+func (s *WebAuthnService) FinishPasskeyLogin(token string, r *http.Request) (*models.User, error) {
+	_, session, err := s.takeCeremony(token, models.WebAuthnCeremonyPasskey)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	lookup := func(_, userHandle []byte) (webauthn.User, error) {
+		id, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := s.userRepo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		user = u
+
+		return s.loadUser(u)
+	}
+
+	_, cred, err := s.wa.FinishPasskeyLogin(lookup, *session, r)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Authenticator.CloneWarning {
+		return user, ErrCloneWarning
+	}
+
+	if err := s.touch(cred); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// StartWebAuthnCeremonyCleanup drops abandoned ceremonies. Call once at
+// startup.
+func StartWebAuthnCeremonyCleanup(s *WebAuthnService, interval time.Duration) {
+	go func() {
+		for {
+			if err := s.repo.PurgeCeremonies(time.Now()); err != nil {
+				log.Println("webauthn ceremony cleanup:", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func (s *WebAuthnService) saveCeremony(
+	kind string,
+	userID, mfaChallengeID *uuid.UUID,
+	session *webauthn.SessionData,
+) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	token := uuid.NewString()
+	err = s.repo.CreateCeremony(HashToken(token), &models.WebAuthnCeremony{
+		Kind:           kind,
+		UserID:         userID,
+		MFAChallengeID: mfaChallengeID,
+		Session:        data,
+		ExpiresAt:      time.Now().Add(ceremonyTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *WebAuthnService) takeCeremony(token, kind string) (*models.WebAuthnCeremony, *webauthn.SessionData, error) {
+	if token == "" {
+		return nil, nil, ErrCeremonyInvalid
+	}
+
+	ceremony, err := s.repo.TakeCeremony(HashToken(token), kind)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrCeremonyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		return nil, nil, err
+	}
+
+	return ceremony, &session, nil
+}
+
+func (s *WebAuthnService) touch(cred *webauthn.Credential) error {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	return s.repo.TouchCredential(cred.ID, data)
+}
+
+func (s *WebAuthnService) loadUser(user *models.User) (*webauthnUser, error) {
+	stored, err := s.repo.ListCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	wu := &webauthnUser{user: user}
+	for _, c := range stored {
+		var cred webauthn.Credential
+		if err := json.Unmarshal(c.Data, &cred); err != nil {
+			return nil, err
+		}
+		wu.creds = append(wu.creds, cred)
+	}
+
+	return wu, nil
+}
+
+// webauthnUser adapts models.User to the library. The user handle is the raw
+// 16-byte user id, which is how FinishPasskeyLogin finds the account.
+type webauthnUser struct {
+	user  *models.User
+	creds []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	if u.user.Username != "" {
+		return u.user.Username
+	}
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrBlobNotFound is returned by Get when no object exists under the key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque binary objects under slash separated keys.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey rejects empty keys and anything that could escape the store root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// allowedContentTypes are the sniffed media types accepted as attachments.
+// A client supplied Content-Type is never trusted.
+var allowedContentTypes = map[string]bool{
+	"image/png":                true,
+	"image/jpeg":               true,
+	"image/gif":                true,
+	"image/webp":               true,
+	"application/pdf":          true,
+	"application/zip":          true,
+	"application/x-gzip":       true,
+	"text/plain":               true,
+	"application/octet-stream": true, // binary logs, dumps
+}
+
+// SniffContentType detects the content type from the first bytes of a file
+// and reports whether it is allowed as an attachment.
+func SniffContentType(head []byte) (string, bool) {
+	contentType := http.DetectContentType(head)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return contentType, allowedContentTypes[mediaType]
+}
+
+// SanitizeFilename keeps only the base name and drops control characters so
+// the stored name is safe to echo back in Content-Disposition.
+func SanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+
+	if len(name) > 255 {
+		// cut at a rune boundary so a multi-byte character isn't split
+		cut := 255
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
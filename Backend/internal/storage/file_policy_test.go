@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\notes.txt`, "notes.txt"},
+		{"bad\x00name\r\n.txt", "badname.txt"},
+		{`say "hi".txt`, "say hi.txt"},
+		{"", "attachment"},
+		{"..", "attachment"},
+		{"/", "attachment"},
+		// 254 ASCII bytes then a 3-byte rune: the rune must go whole
+		{strings.Repeat("a", 254) + "€€", strings.Repeat("a", 254)},
+		{strings.Repeat("é", 200), strings.Repeat("é", 127)},
+	}
+
+	for _, tt := range tests {
+		got := SanitizeFilename(tt.in)
+		if got != tt.want {
+			t.Errorf("SanitizeFilename(%.40q) = %.40q, want %.40q", tt.in, got, tt.want)
+		}
+		if !utf8.ValidString(got) || len(got) > 255 {
+			t.Errorf("SanitizeFilename(%.40q) = invalid or too long (%d bytes)", tt.in, len(got))
+		}
+	}
+}
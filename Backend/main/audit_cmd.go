@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"ticketapp/internal/models"
+	"ticketapp/internal/repositories"
+	"ticketapp/internal/services"
+)
+
+const auditUsage = `usage: ticketapp audit <command>
+
+commands:
+  verify [-checkpoints file] [-min-head id]
+               walk the audit hash chain and report the first broken link;
+               the chain must still match every checkpoint in an earlier
+               export and reach at least event id
+  checkpoint   sign a checkpoint of the current chain head
+  export       print all signed checkpoints and the public key as JSON
+
+checkpoint and export need AUDIT_SIGNING_KEY; verify checks signatures
+with it when it is set, or else with the public key of the export.`
+
+// auditExport is the document written by "audit export" and read back by
+// "audit verify -checkpoints".
+type auditExport struct {
+	KeyID       string                   `json:"key_id"`
+	PublicKey   []byte                   `json:"public_key"`
+	Checkpoints []models.AuditCheckpoint `json:"checkpoints"`
+}
+
+// runAudit implements the "audit" subcommand and returns the exit code.
+func runAudit(repo repositories.AuditRepository, args []string) int {
+	if len(args) == 0 || (args[0] != "verify" && len(args) != 1) {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+
+	var signer *services.AuditSigner
+	if seed := os.Getenv("AUDIT_SIGNING_KEY"); seed != "" {
+		var err error
+		if signer, err = services.NewAuditSigner(repo, seed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	switch args[0] {
+	case "verify":
+		fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+		exportFile := fs.String("checkpoints", "", "checkpoints exported earlier with `audit export`")
+		minHead := fs.Int64("min-head", 0, "lowest acceptable id of the newest chained event")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, auditUsage)
+			return 2
+		}
+
+		var pub ed25519.PublicKey
+		if signer != nil {
+			pub = signer.PublicKey()
+		}
+
+		opts := services.AuditVerifyOptions{MinEventID: *minHead}
+		if *exportFile != "" {
+			export, err := readAuditExport(*exportFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "read audit export:", err)
+				return 1
+			}
+			if pub == nil {
+				pub = export.PublicKey
+			}
+			opts.Exported = export.Checkpoints
+		}
+
+		report, err := services.VerifyAuditChain(repo, pub, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "verify audit chain:", err)
+			return 1
+		}
+
+		fmt.Printf("%d events (%d before chaining), %d checkpoints, head at event %d\n",
+			report.Events, report.Unchained, report.Checkpoints, report.Head)
+		if pub == nil {
+			fmt.Println("checkpoint signatures not checked: AUDIT_SIGNING_KEY is not set")
+		}
+		if report.Broken != "" {
+			if report.BrokenAt > 0 {
+				fmt.Printf("BROKEN at event %d: %s\n", report.BrokenAt, report.Broken)
+			} else {
+				fmt.Printf("BROKEN: %s\n", report.Broken)
+			}
+			return 1
+		}
+		fmt.Println("OK")
+		return 0
+
+	case "checkpoint":
+		if signer == nil {
+			fmt.Fprintln(os.Stderr, "AUDIT_SIGNING_KEY is not set")
+			return 1
+		}
+
+		c, err := signer.Checkpoint()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "audit checkpoint:", err)
+			return 1
+		}
+		if c == nil {
+			fmt.Println("nothing new since the last checkpoint")
+			return 0
+		}
+		fmt.Printf("checkpoint %d at event %d\n", c.ID, c.LastEventID)
+		return 0
+
+	case "export":
+		if signer == nil {
+			fmt.Fprintln(os.Stderr, "AUDIT_SIGNING_KEY is not set")
+			return 1
+		}
+
+		checkpoints, err := repo.ListCheckpoints()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "list audit checkpoints:", err)
+			return 1
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(auditExport{signer.KeyID(), signer.PublicKey(), checkpoints}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, auditUsage)
+	return 2
+}
+
+func readAuditExport(path string) (*auditExport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export auditExport
+	if err := json.Unmarshal(b, &export); err != nil {
+		return nil, err
+	}
+	if len(export.PublicKey) != 0 && len(export.PublicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("export has an invalid public key")
+	}
+	return &export, nil
+}
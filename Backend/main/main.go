@@ -1,20 +1,27 @@
 package main
 
 import (
-	
+	"context"
+	"encoding/base64"
+
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"ticketapp/internal/db"
 	"ticketapp/internal/handlers"
 	"ticketapp/internal/middlewares"
 	"ticketapp/internal/repositories"
 	"ticketapp/internal/router"
 	"ticketapp/internal/services"
+	"ticketapp/internal/storage"
+	"time"
 
 	"github.com/joho/godotenv"
-	
+	"github.com/pquerna/otp"
 )
+
 func main() {
 	// -------------------------
 	// LOAD ENV
@@ -36,31 +43,294 @@ func main() {
 	// -------------------------
 	userRepo := repositories.NewPostgresUserRepo(database)
 	tokenRepo := repositories.NewPostgresRefreshTokenRepo(database)
-	// auditRepo := repositories.NewAuditRepo(database)
+	ticketRepo := repositories.NewPostgresTicketRepo(database)
+	commentRepo := repositories.NewPostgresCommentRepo(database)
+	attachmentRepo := repositories.NewPostgresAttachmentRepo(database)
+	slaRepo := repositories.NewPostgresSLAPolicyRepo(database)
+	orgRepo := repositories.NewPostgresOrganizationRepo(database)
+	inboundRepo := repositories.NewPostgresInboundMailRepo(database)
+	outboxRepo := repositories.NewPostgresEmailOutboxRepo(database)
+	challengeRepo := repositories.NewPostgresMFAChallengeRepo(database)
+	webauthnRepo := repositories.NewPostgresWebAuthnRepo(database)
+	auditRepo := repositories.NewPostgresAuditRepo(database)
+
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(auditRepo, os.Args[2:]))
+	}
+
+	// -------------------------
+	// BLOB STORAGE
+	// -------------------------
+	var blobStore storage.BlobStore
+	switch os.Getenv("BLOB_STORE") {
+	case "s3":
+		blobStore, err = storage.NewS3Store(storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "./data/blobs"
+		}
+		blobStore, err = storage.NewLocalStore(dir)
+	}
+	if err != nil {
+		log.Fatal("failed to init blob storage:", err)
+	}
+
+	maxAttachment := int64(10 << 20)
+	if v, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		maxAttachment = v
+	}
 
 	// -------------------------
 	// SERVICES
 	// -------------------------
-	jwtService := services.NewJWTService(os.Getenv("JWT_SECRET"))
-	otpService := services.NewOTPService()
+	var jwtKeys *services.JWTKeyRing
+	switch alg := os.Getenv("JWT_SIGNING_ALG"); alg {
+	case "", "HS256":
+		jwtKeys = services.NewHMACKeyRing(os.Getenv("JWT_SECRET"))
+	default:
+		rotateDays, _ := strconv.Atoi(os.Getenv("JWT_ROTATE_DAYS"))
+		overlap, _ := time.ParseDuration(os.Getenv("JWT_KEY_OVERLAP"))
+		// base64 of 32 random bytes; private keys are sealed with it
+		kek, kekErr := base64.StdEncoding.DecodeString(os.Getenv("JWT_KEY_ENCRYPTION_KEY"))
+		if kekErr != nil {
+			log.Fatal("invalid JWT_KEY_ENCRYPTION_KEY:", kekErr)
+		}
+		jwtKeys, err = services.NewJWTKeyRing(
+			repositories.NewPostgresJWTKeyRepo(database),
+			services.JWTKeyConfig{
+				Algorithm:   alg,
+				RotateEvery: time.Duration(rotateDays) * 24 * time.Hour,
+				Overlap:     overlap,
+				KEK:         kek,
+			},
+		)
+		if err != nil {
+			log.Fatal("failed to load jwt keys:", err)
+		}
+		services.StartJWTKeyRotation(jwtKeys)
+	}
+	jwtLeeway, _ := time.ParseDuration(os.Getenv("JWT_LEEWAY"))
+	var jwtAudience []string
+	if v := os.Getenv("JWT_AUDIENCE"); v != "" {
+		jwtAudience = strings.Split(v, ",")
+	}
+	jwtService := services.NewJWTService(jwtKeys, services.JWTConfig{
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: jwtAudience,
+		Leeway:   jwtLeeway,
+	})
+
+	var denylist services.TokenDenylist = services.NewMemoryTokenDenylist()
+	if os.Getenv("TOKEN_DENYLIST") == "postgres" {
+		denylist = repositories.NewPostgresTokenDenylistRepo(database)
+	}
+	services.StartTokenDenylistCleanup(denylist, 5*time.Minute)
+	otpPeriod, _ := strconv.Atoi(os.Getenv("OTP_PERIOD_SECONDS"))
+	otpDigits, _ := strconv.Atoi(os.Getenv("OTP_DIGITS"))
+	otpSkew, _ := strconv.Atoi(os.Getenv("OTP_SKEW"))
+	if os.Getenv("OTP_SKEW") == "" {
+		otpSkew = 1
+	}
+	otpService, err := services.NewOTPService(services.OTPConfig{
+		Issuer:    os.Getenv("OTP_ISSUER"),
+		Period:    time.Duration(otpPeriod) * time.Second,
+		Digits:    otp.Digits(otpDigits),
+		Algorithm: os.Getenv("OTP_ALGORITHM"),
+		Skew:      uint(max(otpSkew, 0)),
+	})
+	if err != nil {
+		log.Fatal("invalid otp config:", err)
+	}
+	slaService := services.NewSLAService()
+
+	var oidcService *services.OIDCService
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		var domains []string
+		if v := os.Getenv("OIDC_ALLOWED_DOMAINS"); v != "" {
+			domains = strings.Split(v, ",")
+		}
+		oidcService, err = services.NewOIDCService(
+			context.Background(),
+			services.OIDCConfig{
+				IssuerURL:      issuer,
+				ClientID:       os.Getenv("OIDC_CLIENT_ID"),
+				ClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+				RedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+				ProvisionUsers: os.Getenv("OIDC_PROVISION_USERS") == "true",
+				AllowedDomains: domains,
+			},
+			repositories.NewPostgresOIDCRepo(database),
+			userRepo,
+		)
+		if err != nil {
+			log.Fatal("failed to init sso:", err)
+		}
+	}
+
+	var webauthnService *services.WebAuthnService
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		webauthnService, err = services.NewWebAuthnService(services.WebAuthnConfig{
+			RPID:          rpID,
+			RPDisplayName: os.Getenv("WEBAUTHN_RP_NAME"),
+			RPOrigins:     strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ","),
+		}, webauthnRepo, userRepo)
+		if err != nil {
+			log.Fatal("invalid webauthn config:", err)
+		}
+		services.StartWebAuthnCeremonyCleanup(webauthnService, 10*time.Minute)
+	}
+
+	var assigner *services.AssignmentService
+	if strategy := os.Getenv("ASSIGNMENT_STRATEGY"); strategy != "off" {
+		assigner = services.NewAssignmentService(userRepo, strategy)
+	}
+
+	if seed := os.Getenv("AUDIT_SIGNING_KEY"); seed != "" {
+		auditSigner, err := services.NewAuditSigner(auditRepo, seed)
+		if err != nil {
+			log.Fatal("failed to load audit signing key:", err)
+		}
+		interval, _ := time.ParseDuration(os.Getenv("AUDIT_CHECKPOINT_INTERVAL"))
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		services.StartAuditCheckpoints(auditSigner, interval)
+	}
+
+	services.StartSLAEvaluator(ticketRepo, time.Minute)
+
+	// -------------------------
+	// EMAIL
+	// -------------------------
+	// replies to a per-ticket address are trusted as the notified user's
+	var replyAddresses *services.ReplyAddresses
+	if addr := os.Getenv("INBOUND_REPLY_ADDRESS"); addr != "" {
+		replyAddresses, err = services.NewReplyAddresses(addr, os.Getenv("INBOUND_REPLY_SECRET"))
+		if err != nil {
+			log.Fatal("invalid inbound reply address:", err)
+		}
+	}
+
+	emailSvc, err := services.NewEmailService(outboxRepo, os.Getenv("APP_BASE_URL"), replyAddresses)
+	if err != nil {
+		log.Fatal("failed to load email templates:", err)
+	}
+
+	var emailSender services.EmailSender = services.LogSender{}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		emailSender, err = services.NewSMTPSender(services.SMTPConfig{
+			Host:           host,
+			Port:           port,
+			Username:       os.Getenv("SMTP_USERNAME"),
+			Password:       os.Getenv("SMTP_PASSWORD"),
+			From:           os.Getenv("SMTP_FROM"),
+			AllowPlaintext: os.Getenv("SMTP_ALLOW_PLAINTEXT") == "true",
+		})
+		if err != nil {
+			log.Fatal("invalid smtp config:", err)
+		}
+	} else {
+		log.Println("SMTP_HOST not set, emails will only be logged")
+	}
+
+	services.StartEmailOutboxWorker(outboxRepo, emailSender, 10*time.Second)
 
 	// -------------------------
 	// HANDLERS
 	// -------------------------
+	maxLoginFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES"))
+	maxIPLoginFailures, _ := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_FAILURES"))
+	loginLockout, _ := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT"))
+	loginGuard := services.NewLoginGuard(
+		repositories.NewPostgresLoginFailureRepo(database),
+		services.LoginGuardConfig{
+			MaxAccountFailures: maxLoginFailures,
+			MaxIPFailures:      maxIPLoginFailures,
+			Lockout:            loginLockout,
+		},
+	)
+	services.StartLoginFailureCleanup(loginGuard, 10*time.Minute)
+
 	authHandler := handlers.NewAuthHandler(
 		userRepo,
 		tokenRepo,
+		challengeRepo,
+		auditRepo,
 		jwtService,
+		denylist,
+		loginGuard,
 		otpService,
-		
+		webauthnService,
+		oidcService,
+		emailSvc,
 	)
 
-	emailSvc := services.NewEmailService()
+	adminHandler := handlers.NewAdminHandler(
+		userRepo,
+		orgRepo,
+		tokenRepo,
+		denylist,
+		auditRepo,
+		loginGuard,
+		emailSvc,
+	)
 
-adminHandler := handlers.NewAdminHandler(
-	userRepo,
-	emailSvc,
-)
+	ticketService := services.NewTicketService(slaRepo, slaService, assigner)
+
+	ticketHandler := handlers.NewTicketHandler(
+		ticketRepo,
+		userRepo,
+		attachmentRepo,
+		blobStore,
+		ticketService,
+		services.NewTicketWorkflow(),
+		slaService,
+		emailSvc,
+	)
+	commentHandler := handlers.NewCommentHandler(ticketRepo, commentRepo, userRepo, emailSvc)
+	attachmentHandler := handlers.NewAttachmentHandler(
+		ticketRepo,
+		commentRepo,
+		attachmentRepo,
+		blobStore,
+		maxAttachment,
+	)
+	slaHandler := handlers.NewSLAPolicyHandler(slaRepo)
+	agentHandler := handlers.NewAgentHandler(userRepo)
+	orgHandler := handlers.NewOrganizationHandler(orgRepo, userRepo)
+
+	mailIngest := services.NewMailIngestService(
+		userRepo,
+		ticketRepo,
+		commentRepo,
+		attachmentRepo,
+		inboundRepo,
+		ticketService,
+		blobStore,
+		maxAttachment,
+		os.Getenv("INBOUND_AUTHSERV_ID"),
+		replyAddresses,
+	)
+	if dir := os.Getenv("INBOUND_MAILDIR"); dir != "" {
+		services.StartMaildirPoller(dir, mailIngest, 30*time.Second)
+	}
+	inboundMailHandler := handlers.NewInboundMailHandler(mailIngest, os.Getenv("INBOUND_MAIL_TOKEN"))
+	sessionHandler := handlers.NewSessionHandler(tokenRepo, denylist)
+	jwksHandler := handlers.NewJWKSHandler(jwtService)
+
+	var rateStore middlewares.RateLimitStore = middlewares.NewMemoryRateLimitStore()
+	if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+		rateStore = repositories.NewPostgresRateLimitRepo(database)
+	}
+	middlewares.StartRateLimitCleanup(rateStore, time.Minute)
 
 	// -------------------------
 	// ROUTER
@@ -68,14 +338,31 @@ adminHandler := handlers.NewAdminHandler(
 	appRouter := router.NewRouter(
 		authHandler,
 		adminHandler,
+		ticketHandler,
+		commentHandler,
+		attachmentHandler,
+		slaHandler,
+		agentHandler,
+		orgHandler,
+		inboundMailHandler,
+		sessionHandler,
+		jwksHandler,
 		jwtService,
+		denylist,
+		rateStore,
 	)
 
 	// -------------------------
 	// GLOBAL MIDDLEWARES
 	// -------------------------
-	handler := middlewares.CORS(appRouter)
-
+	trustedProxies, err := middlewares.ParseTrustedProxies(
+		os.Getenv("TRUSTED_PROXIES"),
+		os.Getenv("TRUSTED_PROXY_HEADER"),
+	)
+	if err != nil {
+		log.Fatal("failed to parse TRUSTED_PROXIES:", err)
+	}
+	handler := middlewares.CORS(middlewares.RealIP(trustedProxies)(appRouter))
 
 	// -------------------------
 	// SERVER
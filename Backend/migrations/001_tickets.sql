@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tickets (
+    id           UUID PRIMARY KEY,
+    subject      TEXT NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    status       TEXT NOT NULL DEFAULT 'open',
+    priority     TEXT NOT NULL DEFAULT 'normal',
+    requester_id UUID NOT NULL REFERENCES users(id),
+    assignee_id  UUID REFERENCES users(id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS tickets_requester_idx ON tickets (requester_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS tickets_assignee_idx  ON tickets (assignee_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS tickets_status_idx    ON tickets (status);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS ticket_status_history (
+    id          UUID PRIMARY KEY,
+    ticket_id   UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    changed_by  UUID NOT NULL REFERENCES users(id),
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ticket_status_history_ticket_idx
+    ON ticket_status_history (ticket_id, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS ticket_comments (
+    id         UUID PRIMARY KEY,
+    ticket_id  UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    parent_id  UUID REFERENCES ticket_comments(id) ON DELETE CASCADE,
+    author_id  UUID NOT NULL REFERENCES users(id),
+    body       TEXT NOT NULL,
+    internal   BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ticket_comments_ticket_idx
+    ON ticket_comments (ticket_id, created_at);
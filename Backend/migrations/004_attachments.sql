@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id           UUID PRIMARY KEY,
+    ticket_id    UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    comment_id   UUID REFERENCES ticket_comments(id) ON DELETE CASCADE,
+    uploader_id  UUID NOT NULL REFERENCES users(id),
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    storage_key  TEXT NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_ticket_idx ON attachments (ticket_id, created_at);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS sla_policies (
+    id                     UUID PRIMARY KEY,
+    organization_id        UUID,
+    priority               TEXT NOT NULL,
+    first_response_minutes INT NOT NULL CHECK (first_response_minutes > 0),
+    resolution_minutes     INT NOT NULL CHECK (resolution_minutes > 0)
+);
+
+-- one policy per (organization, priority); NULL organization is the default
+CREATE UNIQUE INDEX IF NOT EXISTS sla_policies_org_priority_idx
+    ON sla_policies (COALESCE(organization_id, '00000000-0000-0000-0000-000000000000'), priority);
+
+ALTER TABLE tickets
+    ADD COLUMN IF NOT EXISTS first_response_due      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS resolution_due          TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS first_responded_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS resolved_at             TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS sla_paused_at           TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS sla_paused_seconds      BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS first_response_breached BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS resolution_breached     BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS tickets_sla_open_idx
+    ON tickets (resolution_due)
+    WHERE resolved_at IS NULL AND sla_paused_at IS NULL;
+
+INSERT INTO sla_policies (id, organization_id, priority, first_response_minutes, resolution_minutes) VALUES
+    (gen_random_uuid(), NULL, 'urgent', 30,   240),
+    (gen_random_uuid(), NULL, 'high',   60,   480),
+    (gen_random_uuid(), NULL, 'normal', 240,  1440),
+    (gen_random_uuid(), NULL, 'low',    480,  4320)
+ON CONFLICT DO NOTHING;
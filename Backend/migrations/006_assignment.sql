@@ -0,0 +1,7 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_available     BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS last_assigned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_assignable_idx
+    ON users (last_assigned_at NULLS FIRST)
+    WHERE role = 'support' AND is_active AND is_available;
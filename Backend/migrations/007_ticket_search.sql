@@ -0,0 +1,45 @@
+-- search_vector combines the subject (A), description (B) and public
+-- comments (C). Internal notes are left out so customers can't find tickets
+-- by words they are not allowed to read.
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS search_vector TSVECTOR NOT NULL DEFAULT ''::tsvector;
+
+CREATE INDEX IF NOT EXISTS tickets_search_idx ON tickets USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS tickets_created_id_idx ON tickets (created_at DESC, id DESC);
+
+CREATE OR REPLACE FUNCTION ticket_search_vector(t_id UUID, t_subject TEXT, t_description TEXT)
+RETURNS TSVECTOR LANGUAGE SQL STABLE AS $$
+    SELECT setweight(to_tsvector('english', COALESCE(t_subject, '')), 'A')
+        || setweight(to_tsvector('english', COALESCE(t_description, '')), 'B')
+        || setweight(to_tsvector('english', COALESCE(
+               (SELECT string_agg(body, ' ') FROM ticket_comments
+                WHERE ticket_id = t_id AND internal = FALSE), '')), 'C')
+$$;
+
+CREATE OR REPLACE FUNCTION tickets_search_trigger() RETURNS TRIGGER LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := ticket_search_vector(NEW.id, NEW.subject, NEW.description);
+    RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS tickets_search_update ON tickets;
+CREATE TRIGGER tickets_search_update
+    BEFORE INSERT OR UPDATE OF subject, description ON tickets
+    FOR EACH ROW EXECUTE FUNCTION tickets_search_trigger();
+
+CREATE OR REPLACE FUNCTION ticket_comments_search_trigger() RETURNS TRIGGER LANGUAGE plpgsql AS $$
+DECLARE
+    t_id UUID := COALESCE(NEW.ticket_id, OLD.ticket_id);
+BEGIN
+    UPDATE tickets SET search_vector = ticket_search_vector(id, subject, description)
+    WHERE id = t_id;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS ticket_comments_search_update ON ticket_comments;
+CREATE TRIGGER ticket_comments_search_update
+    AFTER INSERT OR UPDATE OR DELETE ON ticket_comments
+    FOR EACH ROW EXECUTE FUNCTION ticket_comments_search_trigger();
+
+UPDATE tickets SET search_vector = ticket_search_vector(id, subject, description);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id         UUID PRIMARY KEY,
+    name       TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id);
+
+ALTER TABLE tickets
+    ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id);
+
+UPDATE tickets t SET organization_id = u.organization_id
+FROM users u
+WHERE u.id = t.requester_id AND t.organization_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS tickets_organization_idx ON tickets (organization_id, created_at DESC);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'sla_policies_organization_fk'
+    ) THEN
+        ALTER TABLE sla_policies
+            ADD CONSTRAINT sla_policies_organization_fk
+            FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE;
+    END IF;
+END $$;
@@ -0,0 +1,6 @@
+-- processed inbound Message-IDs, so redelivered mail is not ingested twice
+CREATE TABLE IF NOT EXISTS inbound_messages (
+    message_id  TEXT PRIMARY KEY,
+    ticket_id   UUID REFERENCES tickets(id) ON DELETE SET NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,21 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_2fa_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- a secret is pending until the user proves they can generate codes from it
+ALTER TABLE otp_secrets
+    ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- secrets that existed before enrollment was tracked were already in use
+UPDATE otp_secrets SET confirmed_at = NOW() WHERE confirmed_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS otp_secrets_user_idx ON otp_secrets (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
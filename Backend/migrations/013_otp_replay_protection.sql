@@ -0,0 +1,3 @@
+-- the last TOTP time-step accepted for the user; codes at or before it are replays
+ALTER TABLE otp_secrets
+    ADD COLUMN IF NOT EXISTS last_used_step BIGINT;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL UNIQUE,
+    name          TEXT NOT NULL DEFAULT '',
+    -- the verified credential record (public key, sign count, flags, ...)
+    credential    JSONB NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_idx ON webauthn_credentials (user_id);
+
+-- server-side state for an in-flight registration or assertion ceremony
+CREATE TABLE IF NOT EXISTS webauthn_ceremonies (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash       TEXT NOT NULL UNIQUE,
+    kind             TEXT NOT NULL,
+    user_id          UUID REFERENCES users(id) ON DELETE CASCADE,
+    mfa_challenge_id UUID REFERENCES mfa_challenges(id) ON DELETE CASCADE,
+    session          JSONB NOT NULL,
+    expires_at       TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,16 @@
+-- a session is the chain of refresh tokens produced by rotating one login;
+-- every token in the chain carries the same session_id
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS session_id         UUID,
+    ADD COLUMN IF NOT EXISTS user_agent         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip_address         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS session_started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS last_used_at       TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE refresh_tokens SET session_id = id WHERE session_id IS NULL;
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN session_id SET DEFAULT gen_random_uuid(),
+    ALTER COLUMN session_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (user_id, session_id);
@@ -0,0 +1,6 @@
+-- revoked tokens are kept so a replayed one can be traced to its session
+-- (token family) and the whole family shut down
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_hash_idx ON refresh_tokens (token_hash);
@@ -0,0 +1,12 @@
+-- access tokens revoked before their natural expiry
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti        TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- "sign out everywhere": tokens issued to the user at or before not_before are rejected
+CREATE TABLE IF NOT EXISTS revoked_user_tokens (
+    user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    not_before TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,9 @@
+-- asymmetric access-token signing keys, shared by every replica.
+-- private_key is PKCS#8 DER, sealed with a key-encryption key since 027.
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    kid         TEXT PRIMARY KEY,
+    algorithm   TEXT NOT NULL,
+    private_key BYTEA NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
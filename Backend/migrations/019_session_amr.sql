@@ -0,0 +1,4 @@
+-- how the session's login was authenticated (RFC 8176 amr values), carried
+-- into every access token issued from it
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS amr TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,18 @@
+-- in-flight authorization-code logins: state -> nonce + PKCE verifier
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state_hash    TEXT PRIMARY KEY,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL
+);
+
+-- which IdP subject a local account is bound to. Once linked, a different
+-- subject presenting the same email cannot take the account over.
+CREATE TABLE IF NOT EXISTS user_identities (
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer     TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject),
+    UNIQUE (user_id, issuer)
+);
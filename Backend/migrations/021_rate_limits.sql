@@ -0,0 +1,13 @@
+-- shared rate-limiter state so every replica enforces the same quotas
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key        TEXT PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_windows (
+    key          TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    count        INT NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
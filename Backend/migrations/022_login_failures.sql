@@ -0,0 +1,14 @@
+-- failed password logins per account (normalized email) and per client IP.
+-- Keyed by the submitted email rather than user id, so unknown addresses are
+-- throttled exactly like real ones.
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope          TEXT NOT NULL CHECK (scope IN ('account', 'ip')),
+    key            TEXT NOT NULL,
+    failures       INT NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until   TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_locked
+    ON login_failures (locked_until) WHERE locked_until IS NOT NULL;
@@ -0,0 +1,21 @@
+-- structured security audit trail
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID,
+    action     TEXT NOT NULL,
+    ip         TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- user_id is the account the event is about; actor_id whoever caused it,
+-- when that is someone else (an admin). Failed logins for unknown emails
+-- have neither.
+ALTER TABLE audit_logs ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_id UUID;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS details JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS audit_logs_user_idx   ON audit_logs (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_actor_idx  ON audit_logs (actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_action_idx ON audit_logs (action, id DESC);
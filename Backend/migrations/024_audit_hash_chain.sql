@@ -0,0 +1,16 @@
+-- tamper-evident audit trail: each entry hashes its content together with
+-- the previous entry's hash. Rows written before this migration keep a NULL
+-- hash and sit outside the chain.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash TEXT;
+
+-- signed statements of the chain head at a point in time, so truncating the
+-- newest entries is detectable too
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id            BIGSERIAL PRIMARY KEY,
+    last_event_id BIGINT NOT NULL,
+    last_hash     TEXT NOT NULL,
+    key_id        TEXT NOT NULL,
+    signature     BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- per-ticket reply address, so replies can be traced to the notified user
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS reply_to TEXT NOT NULL DEFAULT '';
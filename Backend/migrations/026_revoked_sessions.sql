@@ -0,0 +1,5 @@
+-- sessions ended before their access tokens expire; tokens carrying the sid are rejected
+CREATE TABLE IF NOT EXISTS revoked_sessions (
+    session_id UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,5 @@
+-- private_key is now AES-256-GCM sealed with a key-encryption key kept
+-- outside the database; kek_id names that key, '' marks a legacy plaintext
+-- row, which the service seals in place on its next rotation check.
+ALTER TABLE jwt_signing_keys
+    ADD COLUMN IF NOT EXISTS kek_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- how the first login step was passed (amr value), so the second step can
+-- issue tokens that say so: a password, or an SSO login without IdP MFA
+ALTER TABLE mfa_challenges ADD COLUMN IF NOT EXISTS first_factor TEXT NOT NULL DEFAULT 'pwd';
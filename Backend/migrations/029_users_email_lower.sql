@@ -0,0 +1,2 @@
+-- email lookups (login, reset, inbound mail) match case-insensitively
+CREATE INDEX IF NOT EXISTS users_email_lower_idx ON users (lower(email));